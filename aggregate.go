@@ -0,0 +1,87 @@
+package dbmod
+
+import (
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+var aggregateOps = map[string]dbflex.AggrOp{
+	"sum":   "$sum",
+	"avg":   "$avg",
+	"min":   "$min",
+	"max":   "$max",
+	"count": "$count",
+}
+
+// aggregateData runs aggregate of the request within scope of the context and returns each group as a row
+func (m *mod) aggregateData(ctx *kaos.Context, h *datahub.Hub, model *kaos.ServiceModel, payload *AggregateRequest) ([]codekit.M, error) {
+	if payload == nil || (len(payload.GroupBy) == 0 && len(payload.Aggregates) == 0) {
+		return nil, errBadRequest("group by or aggregates is required")
+	}
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return nil, e
+	}
+	dm := getDataModel(model)
+
+	// grouping and aggregating a field exposes its values, so it follows filter policy
+	fieldName := func(name string) (string, error) {
+		fieldName, ft := lookupField(meta.fields, name)
+		if ft == nil {
+			return "", errBadRequest("unknown field %s", name)
+		}
+		if !meta.policy.CanFilter(fieldName) {
+			return "", errBadRequest("field %s can not be aggregated", fieldName)
+		}
+		return fieldName, nil
+	}
+
+	qp := dbflex.NewQueryParam()
+	qp.Where = payload.Where
+	for _, groupField := range payload.GroupBy {
+		name, e := fieldName(groupField)
+		if e != nil {
+			return nil, e
+		}
+		qp.GroupBy = append(qp.GroupBy, name)
+	}
+	for _, item := range payload.Aggregates {
+		op, ok := aggregateOps[strings.ToLower(item.Op)]
+		if !ok {
+			return nil, errBadRequest("unknown aggregate %s", item.Op)
+		}
+		aggr := &dbflex.AggrItem{Op: op, Alias: item.Alias}
+		if item.Field == "" && op == "$count" {
+			aggr.Field = "_id"
+			if idFields, _ := dm.GetID(nil); len(idFields) > 0 {
+				aggr.Field = idFields[0]
+			}
+			if aggr.Alias == "" {
+				aggr.Alias = "Count"
+			}
+		} else if aggr.Field, e = fieldName(item.Field); e != nil {
+			return nil, e
+		}
+		if aggr.Alias == "" {
+			aggr.Alias = aggr.Field
+		}
+		qp.Aggregates = append(qp.Aggregates, aggr)
+	}
+
+	if qp, e = combineQueryParamFromCtx(qp, ctx, meta.policy); e != nil {
+		return nil, e
+	}
+	if ctxFilters := filtersFromCtx(ctx, meta.softDelete); len(ctxFilters) > 0 {
+		qp.Where = combineFilter(qp.Where, joinFilters(dbflex.And, ctxFilters))
+	}
+
+	rows := []codekit.M{}
+	if e = h.PopulateByParm(dm.TableName(), qp, &rows); e != nil {
+		return nil, e
+	}
+	return rows, nil
+}
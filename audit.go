@@ -0,0 +1,170 @@
+package dbmod
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+// AuditedModel can be implemented by orm.DataModel to record every change made through CUDMethods routes
+type AuditedModel interface {
+	Audited() bool
+}
+
+// AuditRecord is a change log of a data
+type AuditRecord struct {
+	ID      string `json:"_id"`
+	Model   string
+	Action  string
+	Keys    string
+	User    string
+	Time    time.Time
+	Changes []*AuditChange
+}
+
+// AuditChange is value of a field before and after a change
+type AuditChange struct {
+	Field  string
+	Before interface{}
+	After  interface{}
+}
+
+// AuditWriter stores and reads AuditRecord, h is the hub of running transaction
+type AuditWriter interface {
+	WriteAudit(ctx *kaos.Context, h *datahub.Hub, rec *AuditRecord) error
+	ReadAudit(ctx *kaos.Context, h *datahub.Hub, model, keys string) ([]*AuditRecord, error)
+}
+
+// DefaultAuditTable is table used to keep AuditRecord when no other is set by SetAuditTable
+const DefaultAuditTable = "DbModAudit"
+
+type hubAuditWriter struct {
+	tableName string
+}
+
+func (w *hubAuditWriter) WriteAudit(ctx *kaos.Context, h *datahub.Hub, rec *AuditRecord) error {
+	return h.InsertAny(w.tableName, rec)
+}
+
+func (w *hubAuditWriter) ReadAudit(ctx *kaos.Context, h *datahub.Hub, model, keys string) ([]*AuditRecord, error) {
+	qp := dbflex.NewQueryParam().SetWhere(dbflex.And(dbflex.Eq("Model", model), dbflex.Eq("Keys", keys))).SetSort("-Time")
+	res := []*AuditRecord{}
+	if e := h.PopulateByParm(w.tableName, qp, &res); e != nil {
+		return nil, e
+	}
+	return res, nil
+}
+
+// SetAuditTable sets table to store AuditRecord using the same hub of the model
+func (m *mod) SetAuditTable(name string) {
+	m.auditWriter = &hubAuditWriter{tableName: name}
+}
+
+// SetAuditWriter replaces the way AuditRecord is stored
+func (m *mod) SetAuditWriter(w AuditWriter) {
+	m.auditWriter = w
+}
+
+// SetAuditUserFn sets function to get user who makes the change, by default it is jwt_reference_id of context
+func (m *mod) SetAuditUserFn(fn func(ctx *kaos.Context) string) {
+	m.auditUserFn = fn
+}
+
+func (m *mod) getAuditWriter() AuditWriter {
+	if m.auditWriter == nil {
+		return &hubAuditWriter{tableName: DefaultAuditTable}
+	}
+	return m.auditWriter
+}
+
+func isAudited(model *kaos.ServiceModel) bool {
+	am, ok := model.Model.(AuditedModel)
+	return ok && am.Audited()
+}
+
+func auditKeys(keys []interface{}) string {
+	return codekit.JsonString(keys)
+}
+
+// loadCurrent returns data currently in database to be audited, nil if it does not exist
+func loadCurrent(h *datahub.Hub, model *kaos.ServiceModel, where *dbflex.Filter) orm.DataModel {
+	dm := getDataModel(model)
+	if count, e := aggregateCount(h, dm, where); e != nil || count == 0 {
+		return nil
+	}
+	if e := h.GetByFilter(dm, where); e != nil {
+		return nil
+	}
+	return dm
+}
+
+// audit records change of a data, before is nil for new data and after is nil for deleted data
+func (m *mod) audit(ctx *kaos.Context, h *datahub.Hub, model *kaos.ServiceModel, action string, keys []interface{}, before, after interface{}) error {
+	if !isAudited(model) || !codekit.HasMember(CUDMethods, action) {
+		return nil
+	}
+
+	user := ""
+	if m.auditUserFn != nil {
+		user = m.auditUserFn(ctx)
+	} else {
+		user, _ = ctx.Data().Get("jwt_reference_id", "").(string)
+	}
+
+	now := time.Now()
+	rec := &AuditRecord{
+		ID:      fmt.Sprintf("%d%s", now.UnixNano(), codekit.RandomString(8)),
+		Model:   getDataModel(model).TableName(),
+		Action:  action,
+		Keys:    auditKeys(keys),
+		User:    user,
+		Time:    now,
+		Changes: auditChanges(before, after),
+	}
+	return m.getAuditWriter().WriteAudit(ctx, h, rec)
+}
+
+func auditChanges(before, after interface{}) []*AuditChange {
+	toM := func(data interface{}) codekit.M {
+		if data == nil || reflect.ValueOf(data).IsZero() {
+			return codekit.M{}
+		}
+		res, _ := codekit.ToM(data)
+		if res == nil {
+			return codekit.M{}
+		}
+		return res
+	}
+	beforeM, afterM := toM(before), toM(after)
+
+	names := []string{}
+	for name := range beforeM {
+		names = append(names, name)
+	}
+	for name := range afterM {
+		if _, ok := beforeM[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []*AuditChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(beforeM[name], afterM[name]) {
+			changes = append(changes, &AuditChange{Field: name, Before: beforeM[name], After: afterM[name]})
+		}
+	}
+	return changes
+}
+
+func dataKeys(dm orm.DataModel) []interface{} {
+	_, keys := dm.GetID(nil)
+	return keys
+}
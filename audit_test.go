@@ -0,0 +1,33 @@
+package dbmod
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sebarcode/codekit"
+)
+
+func TestAuditChanges(t *testing.T) {
+	cases := []struct {
+		name          string
+		before, after interface{}
+		want          []*AuditChange
+	}{
+		{name: "insert", before: nil, after: codekit.M{"Name": "a", "Age": 1},
+			want: []*AuditChange{{Field: "Age", After: 1}, {Field: "Name", After: "a"}}},
+		{name: "delete", before: codekit.M{"Name": "a"}, after: (*testModel)(nil),
+			want: []*AuditChange{{Field: "Name", Before: "a"}}},
+		{name: "update only changed fields", before: codekit.M{"Name": "a", "Age": 1}, after: codekit.M{"Name": "b", "Age": 1},
+			want: []*AuditChange{{Field: "Name", Before: "a", After: "b"}}},
+		{name: "no change", before: codekit.M{"Name": "a"}, after: codekit.M{"Name": "a"}, want: []*AuditChange{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := auditChanges(c.before, c.after)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %s, want %s", codekit.JsonString(got), codekit.JsonString(c.want))
+			}
+		})
+	}
+}
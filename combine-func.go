@@ -1,34 +1,74 @@
 package dbmod
 
 import (
+	"net/http"
 	"reflect"
+	"strconv"
 	"time"
 
 	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
 	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
 )
 
 const (
 	QueryParamTag = "mdb_query_parm"
 	ValidateTag   = "mdb_validate"
 	ValidateFnTag = "mdb_validate_fn"
+	DryRunTag     = "mdb_dry_run"
 )
 
-func combineQueryParamFromCtx(origin *dbflex.QueryParam, ctx *kaos.Context) *dbflex.QueryParam {
+func combineQueryParamFromCtx(origin *dbflex.QueryParam, ctx *kaos.Context, policy *FieldPolicy) (*dbflex.QueryParam, error) {
 	if origin == nil {
 		origin = dbflex.NewQueryParam()
 	}
 
+	// only param from client is checked, the one from context is set by the service itself
+	if e := policy.checkQueryParam(origin); e != nil {
+		return nil, e
+	}
+
 	if origin.Where != nil {
 		filterString2Date(origin.Where)
 	}
 
 	if ctx.Data().Get(QueryParamTag, nil) != nil {
 		other := ctx.Data().Get(QueryParamTag, dbflex.NewQueryParam()).(*dbflex.QueryParam)
-		return combineQueryParam(origin, other)
+		return combineQueryParam(origin, other), nil
 	}
 
-	return origin
+	return origin, nil
+}
+
+// scopedQueryParam combines query param of client with the context,
+// it is QueryParamTag, DbModFilter, DbModSelect and filters of http query
+func scopedQueryParam(ctx *kaos.Context, meta *modelMeta, payload *dbflex.QueryParam) (*dbflex.QueryParam, error) {
+	parm, e := combineQueryParamFromCtx(payload, ctx, meta.policy)
+	if e != nil {
+		return nil, e
+	}
+
+	// setup filter from data's context
+	whereFields := filtersFromCtx(ctx, meta.softDelete)
+	selectFields, _ := ctx.Data().Get("DbModSelect", []string{}).([]string)
+
+	// if from http request and has query
+	if hr, ok := ctx.Data().Get("http_request", nil).(*http.Request); ok {
+		queryFilters, e := parseQueryFilter(meta.fields, meta.policy, hr.URL.Query())
+		if e != nil {
+			return nil, e
+		}
+		whereFields = append(whereFields, queryFilters...)
+	}
+	if len(whereFields) > 0 {
+		parm = combineQueryParam(parm, dbflex.NewQueryParam().SetWhere(joinFilters(dbflex.And, whereFields)))
+	}
+	if len(selectFields) > 0 {
+		parm.SetSelect(selectFields...)
+	}
+	return parm, nil
 }
 
 func combineQueryParam(origin, other *dbflex.QueryParam) *dbflex.QueryParam {
@@ -80,6 +120,99 @@ func combineFilterFromCtx(origin *dbflex.Filter, ctx *kaos.Context) *dbflex.Filt
 	return origin
 }
 
+// isDryRun returns true if dry run is set on context or http request has query dryrun=true
+func isDryRun(ctx *kaos.Context) bool {
+	if v, ok := ctx.Data().Get(DryRunTag, false).(bool); ok && v {
+		return true
+	}
+	if hr, ok := ctx.Data().Get("http_request", nil).(*http.Request); ok {
+		v, _ := strconv.ParseBool(hr.URL.Query().Get("dryrun"))
+		return v
+	}
+	return false
+}
+
+// matchedIDs returns id of data that match the filter, composite id is returned as slice
+func matchedIDs(h *datahub.Hub, dm orm.DataModel, where *dbflex.Filter) ([]interface{}, error) {
+	idFields, _ := dm.GetID(nil)
+	qp := dbflex.NewQueryParam().SetSelect(idFields...)
+	qp.Where = where
+	rows := []codekit.M{}
+	if e := h.PopulateByParm(dm.TableName(), qp, &rows); e != nil {
+		return nil, e
+	}
+
+	ids := make([]interface{}, len(rows))
+	for idx, row := range rows {
+		if len(idFields) == 1 {
+			ids[idx] = row.Get(idFields[0])
+			continue
+		}
+		values := make([]interface{}, len(idFields))
+		for fieldIdx, idField := range idFields {
+			values[fieldIdx] = row.Get(idField)
+		}
+		ids[idx] = values
+	}
+	return ids, nil
+}
+
+// filtersFromCtx returns DbModFilter and tenant of context, data marked as deleted is excluded when soft delete is active
+func filtersFromCtx(ctx *kaos.Context, sd *softDelete) []*dbflex.Filter {
+	ctxFilters, _ := ctx.Data().Get("DbModFilter", []*dbflex.Filter{}).([]*dbflex.Filter)
+	filters := append([]*dbflex.Filter{}, ctxFilters...)
+	if scope := tenantOf(ctx); scope != nil {
+		filters = append(filters, scope.filter())
+	}
+	if sd != nil {
+		filters = append(filters, sd.activeFilter())
+	}
+	return filters
+}
+
+// deleteData removes data or only marks it as deleted when soft delete is active
+func deleteData(h *datahub.Hub, dm orm.DataModel, sd *softDelete) error {
+	if sd == nil {
+		return h.Delete(dm)
+	}
+	values, fields := sd.mark(true)
+	return h.UpdateAny(dm.TableName(), idFilter(dm), values, fields...)
+}
+
+// affectedRows reads number of affected data from result of dbflex Execute, it is false when the driver does not report it
+func affectedRows(res interface{}) (int64, bool) {
+	switch r := res.(type) {
+	case interface{ RowsAffected() (int64, error) }:
+		n, err := r.RowsAffected()
+		return n, err == nil
+	case int:
+		return int64(r), true
+	case int64:
+		return r, true
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(res))
+	if rv.Kind() != reflect.Struct {
+		return 0, false
+	}
+	for _, name := range []string{"MatchedCount", "DeletedCount"} {
+		if fv := rv.FieldByName(name); fv.IsValid() && fv.CanInt() {
+			return fv.Int(), true
+		}
+	}
+	return 0, false
+}
+
+// idFilter returns filter of data's id
+func idFilter(dm orm.DataModel) *dbflex.Filter {
+	idFields, idValues := dm.GetID(nil)
+	filters := make([]*dbflex.Filter, len(idFields))
+	for idx, idField := range idFields {
+		filters[idx] = dbflex.Eq(idField, idValues[idx])
+	}
+	return joinFilters(dbflex.And, filters)
+}
+
 func combineFilter(origin, other *dbflex.Filter) *dbflex.Filter {
 	origin = filterString2Date(origin)
 	other = filterString2Date(other)
@@ -0,0 +1,45 @@
+package dbmod
+
+import (
+	"errors"
+	"testing"
+)
+
+type testSQLResult struct {
+	n   int64
+	err error
+}
+
+func (r testSQLResult) LastInsertId() (int64, error) { return 0, nil }
+func (r testSQLResult) RowsAffected() (int64, error) { return r.n, r.err }
+
+func TestAffectedRows(t *testing.T) {
+	type mongoUpdate struct{ MatchedCount, ModifiedCount int64 }
+	type mongoDelete struct{ DeletedCount int64 }
+
+	cases := []struct {
+		name string
+		res  interface{}
+		want int64
+		ok   bool
+	}{
+		{name: "sql", res: testSQLResult{n: 2}, want: 2, ok: true},
+		{name: "sql without count", res: testSQLResult{err: errors.New("not supported")}},
+		{name: "int", res: 3, want: 3, ok: true},
+		{name: "int64", res: int64(0), want: 0, ok: true},
+		{name: "matched", res: &mongoUpdate{MatchedCount: 1}, want: 1, ok: true},
+		{name: "deleted", res: mongoDelete{DeletedCount: 4}, want: 4, ok: true},
+		{name: "nil", res: nil},
+		{name: "unknown", res: "done"},
+		{name: "struct without count", res: struct{ N int }{N: 1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := affectedRows(c.res)
+			if got != c.want || ok != c.ok {
+				t.Fatalf("got %d %v, want %d %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package dbmod
+
+import (
+	"sync"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+// CountParam is key of dbflex.QueryParam.Param to choose how gets route counts the data,
+// value is one of CountExact (default), CountEstimate or CountNone. Param noCount=true equals to CountNone
+const CountParam = "count"
+
+const (
+	CountExact    = "exact"
+	CountEstimate = "estimate"
+	CountNone     = "none"
+)
+
+// CountEstimator can be implemented by orm.DataModel to provide cheaper count for very large table,
+// ie using collection stats. It is used when client asks for CountEstimate
+type CountEstimator interface {
+	EstimateCount(h *datahub.Hub, where *dbflex.Filter) (int, error)
+}
+
+type countCacheItem struct {
+	count int
+	at    time.Time
+}
+
+type countCache struct {
+	sync.RWMutex
+	ttl   time.Duration
+	items map[string]countCacheItem
+}
+
+func newCountCache(ttl time.Duration) *countCache {
+	return &countCache{ttl: ttl, items: map[string]countCacheItem{}}
+}
+
+// get returns cached count, stale item is accepted when allowStale is true
+func (c *countCache) get(key string, allowStale bool) (int, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	item, ok := c.items[key]
+	if !ok || (!allowStale && time.Since(item.at) > c.ttl) {
+		return 0, false
+	}
+	return item.count, true
+}
+
+func (c *countCache) set(key string, count int) {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	for k, item := range c.items {
+		if now.Sub(item.at) > c.ttl {
+			delete(c.items, k)
+		}
+	}
+	c.items[key] = countCacheItem{count: count, at: now}
+}
+
+// SetCountCache caches count of gets route by table, count mode and filter for given duration, 0 disables the cache
+func (m *mod) SetCountCache(ttl time.Duration) {
+	if ttl <= 0 {
+		m.countCache = nil
+		return
+	}
+	m.countCache = newCountCache(ttl)
+}
+
+func countMode(parm *dbflex.QueryParam) string {
+	if parm == nil || parm.Param == nil {
+		return CountExact
+	}
+	if noCount, ok := parm.Param.Get("noCount", false).(bool); ok && noCount {
+		return CountNone
+	}
+	switch mode := parm.Param.GetString(CountParam); mode {
+	case CountEstimate, CountNone:
+		return mode
+	}
+	return CountExact
+}
+
+func (m *mod) countData(h *datahub.Hub, mdl orm.DataModel, where *dbflex.Filter, mode string) (int, error) {
+	key := ""
+	if m.countCache != nil {
+		key = mdl.TableName() + "|" + mode + "|" + codekit.JsonString(where)
+		if count, ok := m.countCache.get(key, mode == CountEstimate); ok {
+			return count, nil
+		}
+	}
+
+	var (
+		count int
+		e     error
+	)
+	if estimator, ok := mdl.(CountEstimator); ok && mode == CountEstimate {
+		count, e = estimator.EstimateCount(h, where)
+	} else {
+		count, e = aggregateCount(h, mdl, where)
+	}
+	if e != nil {
+		return 0, e
+	}
+
+	if m.countCache != nil {
+		m.countCache.set(key, count)
+	}
+	return count, nil
+}
+
+// aggregateCount counts data using single aggregate command
+func aggregateCount(h *datahub.Hub, mdl orm.DataModel, where *dbflex.Filter) (int, error) {
+	countField := "_id"
+	if idFields, _ := mdl.GetID(nil); len(idFields) > 0 {
+		countField = idFields[0]
+	}
+
+	qp := dbflex.NewQueryParam()
+	qp.Where = where
+	qp.Aggregates = []*dbflex.AggrItem{{Field: countField, Op: "$count", Alias: "RecordCount"}}
+
+	rows := []codekit.M{}
+	if e := h.PopulateByParm(mdl.TableName(), qp, &rows); e != nil {
+		return 0, e
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].GetInt("RecordCount"), nil
+}
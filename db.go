@@ -1,12 +1,12 @@
 package dbmod
 
 import (
-	"errors"
 	"fmt"
-	"net/http"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 
 	"git.kanosolution.net/kano/dbflex"
 	"git.kanosolution.net/kano/dbflex/orm"
@@ -17,11 +17,18 @@ import (
 )
 
 type mod struct {
-	hubFn func(ctx *kaos.Context) *datahub.Hub
+	hubFn           func(ctx *kaos.Context) *datahub.Hub
+	countCache      *countCache
+	errorOnNotFound bool
+	metas           sync.Map
+	auditWriter     AuditWriter
+	auditUserFn     func(ctx *kaos.Context) string
+	tenancy         *tenancy
+	routeMWs        map[string][]kaos.MWFunc
 }
 
 var (
-	CUDMethods = []string{"save", "delete", "deletemany", "deletemany", "deletequery"}
+	CUDMethods = []string{"save", "insert", "update", "fieldupdate", "upsert", "patch", "import", "delete", "deletemany", "deletequery"}
 )
 
 func New() *mod {
@@ -40,6 +47,11 @@ func (m *mod) SetHubFn(fn func(ctx *kaos.Context) *datahub.Hub) {
 	m.hubFn = fn
 }
 
+// SetErrorOnNotFound makes delete route returns not found error instead of 0 when no data matches the id
+func (m *mod) SetErrorOnNotFound(v bool) {
+	m.errorOnNotFound = v
+}
+
 func (m *mod) Name() string {
 	return "sbr-mod-db"
 }
@@ -77,6 +89,12 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 	routes := []*kaos.ServiceRoute{}
 	rt := model.ModelType
 	alias := model.Name
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return nil, e
+	}
+	fields := meta.fields
+	softDel := meta.softDelete
 
 	var sr *kaos.ServiceRoute
 	disabledRoutes := model.DisableRoutes()
@@ -104,68 +122,63 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.ResponseType = reflect.PointerTo(reflect.SliceOf(rt))
 		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *dbflex.QueryParam) (interface{}, error) {
 			h := m.getHub(ctx)
-			parm := combineQueryParamFromCtx(payload, ctx)
-
-			// setup filter from data's context
-			whereFields, _ := ctx.Data().Get("DbModFilter", []*dbflex.Filter{}).([]*dbflex.Filter)
-			selectFields, _ := ctx.Data().Get("DbModSelect", []string{}).([]string)
-
-			// if from http request and has query
-			if hr, ok := ctx.Data().Get("http_request", nil).(*http.Request); ok {
-				queryValues := hr.URL.Query()
-				for k, vs := range queryValues {
-					if len(vs) > 0 {
-						whereFields = append(whereFields, dbflex.Eq(k, vs[0]))
-					}
-				}
-				if len(whereFields) == 1 {
-					parm = combineQueryParam(parm, dbflex.NewQueryParam().SetWhere(whereFields[0]))
-				} else if len(whereFields) > 1 {
-					parm = combineQueryParam(parm, dbflex.NewQueryParam().SetWhere(dbflex.And(whereFields...)))
-				}
-			}
-			if len(selectFields) > 0 {
-				parm.SetSelect(selectFields...)
+			parm, e := scopedQueryParam(ctx, meta, payload)
+			if e != nil {
+				return nil, e
 			}
 
 			mdl := reflect.New(rt).Interface().(orm.DataModel)
 			dest := reflect.New(reflect.SliceOf(rt)).Interface()
 
+			// keyset pagination, cursor filter is not part of count
+			countWhere := parm.Where
+			keyset := parm.Param != nil && parm.Param.Has(CursorParam)
+			if keyset {
+				idFields, _ := mdl.GetID(nil)
+				parm.Sort = keysetSort(parm.Sort, idFields)
+				parm.Skip = 0
+				if len(parm.Select) > 0 {
+					for _, sort := range parm.Sort {
+						if sortField := strings.TrimLeft(sort, "-+"); !codekit.HasMember(parm.Select, sortField) {
+							parm.Select = append(parm.Select, sortField)
+						}
+					}
+				}
+				if cursor := parm.Param.GetString(CursorParam); cursor != "" {
+					cursorWhere, e := decodeCursor(fields, parm.Sort, cursor)
+					if e != nil {
+						return nil, e
+					}
+					parm.Where = combineFilter(parm.Where, cursorWhere)
+				}
+			}
+
 			// get data
-			e := h.Gets(mdl, parm, dest)
+			e = h.Gets(mdl, parm, dest)
 			if e != nil {
 				return nil, e
 			}
 
-			// get count
-			cmd := dbflex.From(mdl.TableName()).Select("_id")
-			if parm != nil && parm.Where != nil {
-				cmd.Where(parm.Where)
+			next := ""
+			if rows := reflect.ValueOf(dest).Elem(); keyset && parm.Take > 0 && rows.Len() == parm.Take {
+				if next, e = encodeCursor(fields, parm.Sort, rows.Index(rows.Len()-1)); e != nil {
+					return nil, e
+				}
 			}
 
-			//cmd.Select("count(*) as RecordCount")
-			recordCount := 0
-			noCount := false
-			if parm.Param != nil {
-				noCount = parm.Param.Get("noCount", false).(bool)
-			}
-			if !noCount {
-				conn, err := h.GetClassicConnection()
-				if err == nil {
-					func() {
-						cursorCount := conn.Cursor(cmd, nil)
-						defer cursorCount.Close()
-						defer func() {
-							conn.Close()
-						}()
-						recordCount = cursorCount.Count()
-					}()
+			res := codekit.M{}.Set("data", dest)
+			if mode := countMode(parm); mode != CountNone {
+				recordCount, e := m.countData(h, mdl, countWhere, mode)
+				if e != nil {
+					return nil, e
 				}
+				res.Set("count", recordCount)
 			}
-
-			m := codekit.M{}.Set("data", dest).Set("count", recordCount)
-			model.CallHook("PostGets", ctx, m)
-			return m, nil
+			if keyset {
+				res.Set("next", next)
+			}
+			model.CallHook("PostGets", ctx, res)
+			return res, nil
 		})
 		routes = append(routes, sr)
 	}
@@ -178,36 +191,16 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.RequestType = reflect.TypeOf(new(dbflex.QueryParam))
 		sr.ResponseType = reflect.PointerTo(reflect.SliceOf(rt))
 		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *dbflex.QueryParam) (interface{}, error) {
-			parm := combineQueryParamFromCtx(payload, ctx)
+			parm, e := scopedQueryParam(ctx, meta, payload)
+			if e != nil {
+				return nil, e
+			}
 			mdl := reflect.New(rt).Interface().(orm.DataModel)
 			dest := reflect.New(reflect.SliceOf(rt)).Interface()
 
-			// setup filter from data's context
-			whereFields, _ := ctx.Data().Get("DbModFilter", []*dbflex.Filter{}).([]*dbflex.Filter)
-			selectFields, _ := ctx.Data().Get("DbModSelect", []string{}).([]string)
-
-			//-- check if it is a http request and has query
-			if hr, ok := ctx.Data().Get("http_request", nil).(*http.Request); ok {
-				queryValues := hr.URL.Query()
-				for k, vs := range queryValues {
-					if len(vs) > 0 {
-						whereFields = append(whereFields, dbflex.Eq(k, vs[0]))
-					}
-				}
-				if len(whereFields) == 1 {
-					parm = combineQueryParam(parm, dbflex.NewQueryParam().SetWhere(whereFields[0]))
-				} else if len(whereFields) > 1 {
-					parm = combineQueryParam(parm, dbflex.NewQueryParam().SetWhere(dbflex.And(whereFields...)))
-				}
-			}
-
-			if len(selectFields) > 0 {
-				parm.SetSelect(selectFields...)
-			}
-
 			// get data
 			h := m.getHub(ctx)
-			e := h.Gets(mdl, parm, dest)
+			e = h.Gets(mdl, parm, dest)
 			if e != nil {
 				return nil, e
 			}
@@ -217,46 +210,74 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		routes = append(routes, sr)
 	}
 
+	//-- export
+	if !codekit.HasMember(disabledRoutes, "export") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "export")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(dbflex.NewQueryParam())
+		sr.ResponseType = reflect.TypeOf(int(0))
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *dbflex.QueryParam) (int, error) {
+			return m.exportData(ctx, m.getHub(ctx), model, payload)
+		})
+		routes = append(routes, sr)
+	}
+
+	//-- aggregate
+	if !codekit.HasMember(disabledRoutes, "aggregate") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "aggregate")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(&AggregateRequest{})
+		sr.ResponseType = reflect.TypeOf([]codekit.M{})
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *AggregateRequest) ([]codekit.M, error) {
+			return m.aggregateData(ctx, m.getHub(ctx), model, payload)
+		})
+		routes = append(routes, sr)
+	}
+
+	//-- distinct
+	if !codekit.HasMember(disabledRoutes, "distinct") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "distinct")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(&DistinctRequest{})
+		sr.ResponseType = reflect.TypeOf([]interface{}{})
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *DistinctRequest) (interface{}, error) {
+			return m.distinctData(ctx, m.getHub(ctx), model, payload)
+		})
+		routes = append(routes, sr)
+	}
+
 	//-- get
 	if !codekit.HasMember(disabledRoutes, "get") {
 		sr = new(kaos.ServiceRoute)
 		sr.Path = filepath.Join(svc.BasePoint(), alias, "get")
 		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
-		sr.RequestType = reflect.TypeOf([]interface{}{})
+		sr.RequestType = keysType
 		sr.ResponseType = reflect.TypeOf(reflect.PointerTo(rt))
-		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, keys []interface{}) (orm.DataModel, error) {
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, keys interface{}) (orm.DataModel, error) {
 			h := m.getHub(ctx)
 			dm := getDataModel(model)
-
-			// build filter
-			idFields, _ := dm.GetID(nil)
-			filter := []*dbflex.Filter{}
-			for idx, idField := range idFields {
-				filter = append(filter, dbflex.Eq(idField, keys[idx]))
+			values, e := setKeys(dm, keys)
+			if e != nil {
+				return nil, e
 			}
 
-			// get filter from context
-			ctxFilters := ctx.Data().Get("DbModFilter", []*dbflex.Filter{}).([]*dbflex.Filter)
-			filter = append(filter, ctxFilters...)
-
-			var e error
-			if len(filter) == 1 {
-				e = h.GetByFilter(dm, filter[0])
-			} else if len(filter) > 1 {
-				e = h.GetByFilter(dm, dbflex.And(filter...))
-			}
+			// build filter, with filter from context
+			filter := append([]*dbflex.Filter{keysFilter(dm, values)}, filtersFromCtx(ctx, softDel)...)
+			e = h.GetByFilter(dm, joinFilters(dbflex.And, filter))
 			if e != nil {
 				return dm, e
 			}
 
-			if ctx.Data().Get(ValidateTag, false).(bool) {
-				fn := ctx.Data().Get(ValidateFnTag, func(codekit.M) bool { return false }).(func(codekit.M) bool)
-				dm_m, _ := codekit.ToM(dm)
-				if !fn(dm_m) {
-					return dm, errors.New("validate data error")
-				}
+			if e = validateFromCtx(ctx, dm); e != nil {
+				return dm, e
 			}
 
+			if meta.version != nil {
+				meta.version.setETag(ctx, dm)
+			}
 			model.CallHook("PostGet", ctx, dm)
 			return dm, e
 		})
@@ -271,52 +292,12 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.RequestType = reflect.TypeOf(model.Model)
 		sr.ResponseType = reflect.TypeOf(model.Model)
 		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, dm orm.DataModel) (orm.DataModel, error) {
-			h := m.getHub(ctx)
-			var (
-				e  error
-				tx *datahub.Hub
-			)
-
-			tx, e = h.BeginTx()
-			if e != nil {
-				tx = h
-			}
-			defer func() {
-				if e == nil {
-					tx.Commit()
-				} else {
-					tx.Rollback()
-				}
-			}()
-
-			if dmIsNil(dm) {
-				return nil, fmt.Errorf("data is nil")
-			}
-
-			if ctx.Data().Get(ValidateTag, false).(bool) {
-				fn := ctx.Data().Get(ValidateFnTag, func(codekit.M) bool { return false }).(func(codekit.M) bool)
-				dm_m, _ := codekit.ToM(dm)
-				if !fn(dm_m) {
-					return dm, errors.New("validate data error")
-				}
-				serde.Serde(dm_m, dm)
-			}
-
-			if e = model.CallHook("PreSave", ctx, dm); e != nil {
-				return dm, e
-			}
-			fields := ctx.Data().Get("Fields", []string{}).([]string)
-			if len(fields) == 0 {
-				e = tx.Save(dm)
-			} else {
-				e = tx.Save(dm, fields...)
-			}
-			if e != nil {
-				return dm, ctx.Log().Error2("error when save data", "save error: %s", e.Error())
-			}
-			if e = model.CallHook("PostSave", ctx, dm); e != nil {
-				return dm, e
+			if meta.version != nil {
+				meta.version.ifMatch(ctx, dm)
 			}
+			e := withTx(ctx, m.getHub(ctx), func(tx *datahub.Hub) error {
+				return m.writeData(ctx, tx, model, dm, writeSave)
+			})
 			return dm, e
 		})
 		routes = append(routes, sr)
@@ -330,38 +311,9 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.RequestType = reflect.TypeOf(model.Model)
 		sr.ResponseType = reflect.TypeOf(model.Model)
 		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, dm orm.DataModel) (orm.DataModel, error) {
-			h := m.getHub(ctx)
-
-			var (
-				e  error
-				tx *datahub.Hub
-			)
-
-			tx, e = h.BeginTx()
-			if e != nil {
-				tx = h
-			}
-			defer func() {
-				if e == nil {
-					tx.Commit()
-				} else {
-					tx.Rollback()
-				}
-			}()
-
-			if dmIsNil(dm) {
-				return nil, fmt.Errorf("data is nil")
-			}
-
-			if e = model.CallHook("PreSave", ctx, dm); e != nil {
-				return dm, e
-			}
-			if e = tx.Insert(dm); e != nil {
-				return dm, e
-			}
-			if e = model.CallHook("PostSave", ctx, dm); e != nil {
-				return dm, e
-			}
+			e := withTx(ctx, m.getHub(ctx), func(tx *datahub.Hub) error {
+				return m.writeData(ctx, tx, model, dm, writeInsert)
+			})
 			return dm, e
 		})
 		routes = append(routes, sr)
@@ -375,45 +327,86 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.RequestType = reflect.TypeOf(model.Model)
 		sr.ResponseType = reflect.TypeOf(model.Model)
 		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, dm orm.DataModel) (orm.DataModel, error) {
-			h := m.getHub(ctx)
+			if meta.version != nil {
+				meta.version.ifMatch(ctx, dm)
+			}
+			e := withTx(ctx, m.getHub(ctx), func(tx *datahub.Hub) error {
+				return m.writeData(ctx, tx, model, dm, writeUpdate)
+			})
+			return dm, e
+		})
+		routes = append(routes, sr)
+	}
 
-			var (
-				e  error
-				tx *datahub.Hub
-			)
+	//-- upsert
+	if !codekit.HasMember(disabledRoutes, "upsert") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "upsert")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(&UpsertRequest{})
+		sr.ResponseType = reflect.TypeOf(model.Model)
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *UpsertRequest) (orm.DataModel, error) {
+			var dm orm.DataModel
+			e := withTx(ctx, m.getHub(ctx), func(tx *datahub.Hub) error {
+				var e error
+				dm, e = m.upsertData(ctx, tx, model, payload)
+				return e
+			})
+			return dm, e
+		})
+		routes = append(routes, sr)
+	}
 
-			tx, e = h.BeginTx()
-			if e != nil {
-				tx = h
-			}
-			defer func() {
-				if e == nil {
-					tx.Commit()
-				} else {
-					tx.Rollback()
-				}
-			}()
+	//-- patch
+	if !codekit.HasMember(disabledRoutes, "patch") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "patch")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(&PatchRequest{})
+		sr.ResponseType = reflect.TypeOf(model.Model)
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *PatchRequest) (orm.DataModel, error) {
+			var dm orm.DataModel
+			e := withTx(ctx, m.getHub(ctx), func(tx *datahub.Hub) error {
+				var e error
+				dm, e = m.patchData(ctx, tx, model, payload)
+				return e
+			})
+			return dm, e
+		})
+		routes = append(routes, sr)
+	}
 
-			if dmIsNil(dm) {
-				return nil, fmt.Errorf("data is nil")
-			}
+	//-- import
+	if !codekit.HasMember(disabledRoutes, "import") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "import")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(&ImportRequest{})
+		sr.ResponseType = reflect.TypeOf(&ImportResult{})
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *ImportRequest) (*ImportResult, error) {
+			return m.importData(ctx, m.getHub(ctx), model, payload)
+		})
+		routes = append(routes, sr)
+	}
 
-			if e = model.CallHook("PreSave", ctx, dm); e != nil {
-				return dm, e
-			}
-			fields := ctx.Data().Get("Fields", []string{}).([]string)
-			if len(fields) == 0 {
-				e = tx.Update(dm)
-			} else {
-				e = tx.Update(dm, fields...)
-			}
+	//-- savemany, insertmany, updatemany
+	for _, op := range []string{writeSave, writeInsert, writeUpdate} {
+		routeName := op + "many"
+		if codekit.HasMember(disabledRoutes, routeName) {
+			continue
+		}
+		op := op
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, routeName)
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.SliceOf(reflect.TypeOf(model.Model))
+		sr.ResponseType = reflect.TypeOf([]*BulkResult{})
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload interface{}) ([]*BulkResult, error) {
+			dms, e := toDataModels(model, payload)
 			if e != nil {
-				return dm, e
-			}
-			if e = model.CallHook("PostSave", ctx, dm); e != nil {
-				return dm, e
+				return nil, e
 			}
-			return dm, e
+			return m.writeMany(ctx, model, dms, op)
 		})
 		routes = append(routes, sr)
 	}
@@ -428,13 +421,70 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, payload *UpdateFieldRequest) (codekit.M, error) {
 			h := m.getHub(ctx)
 			obj := payload.Model
-			filters := []*dbflex.Filter{dbflex.Eq("_id", obj.GetString("_id"))}
-			ctxFilters := ctx.Data().Get("DbModFilter", []*dbflex.Filter{}).([]*dbflex.Filter)
-			if len(ctxFilters) > 0 {
-				filters = append(filters, ctxFilters...)
+			keyData := getDataModel(model)
+			keys := payload.Keys
+			if keys == nil {
+				keys = keysOf(keyData, obj)
+			}
+			keyValues, e := setKeys(keyData, keys)
+			if e != nil {
+				return obj, e
 			}
-			tableName := model.Model.(orm.DataModel).TableName()
-			e := h.UpdateAny(tableName, dbflex.And(filters...), obj, payload.Fields...)
+			where := joinFilters(dbflex.And, append([]*dbflex.Filter{keysFilter(keyData, keyValues)}, filtersFromCtx(ctx, softDel)...))
+			if scope := tenantOf(ctx); scope != nil {
+				obj.Set(scope.field, scope.value)
+			}
+
+			e = withTx(ctx, h, func(tx *datahub.Hub) error {
+				current := loadCurrent(tx, model, where)
+				if current == nil {
+					return errNotFound("data not found")
+				}
+
+				// validation and hooks work on a copy of current data merged with supplied fields
+				fields := payload.Fields
+				if len(fields) == 0 {
+					fields = obj.Keys()
+				}
+				dm := getDataModel(model)
+				if e := serde.Serde(current, dm); e != nil {
+					return e
+				}
+				if e := serde.Serde(obj, dm); e != nil {
+					return e
+				}
+				if e := m.validateData(ctx, model, "fieldupdate", dm, fields...); e != nil {
+					return e
+				}
+				if e := callHook(ctx, model, "PreFieldUpdate", "PreSave", dm); e != nil {
+					return e
+				}
+
+				// take back changes made by hook on supplied fields
+				if dmM, err := codekit.ToM(dm); err == nil {
+					for _, field := range fields {
+						if v, ok := dmM[field]; ok {
+							obj.Set(field, v)
+						}
+					}
+				}
+
+				tableName := model.Model.(orm.DataModel).TableName()
+				if meta.version != nil {
+					if e := meta.version.updateFields(ctx, tx, tableName, current, where, obj, payload.Fields...); e != nil {
+						return e
+					}
+				} else if e := tx.UpdateAny(tableName, where, obj, payload.Fields...); e != nil {
+					return e
+				}
+				if e := callHook(ctx, model, "PostFieldUpdate", "PostSave", dm); e != nil {
+					return e
+				}
+				if !isAudited(model) {
+					return nil
+				}
+				return m.audit(ctx, tx, model, "fieldupdate", keyValues, current, loadCurrent(tx, model, where))
+			})
 			return obj, e
 		})
 		routes = append(routes, sr)
@@ -453,34 +503,47 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 			if dmIsNil(dm) {
 				return 0, fmt.Errorf("data is nil")
 			}
-
-			if ctx.Data().Get(ValidateTag, false).(bool) {
-				fn := ctx.Data().Get(ValidateFnTag, func(codekit.M) bool { return false }).(func(codekit.M) bool)
-				dm_m, _ := codekit.ToM(dm)
-				if !fn(dm_m) {
-					return 0, errors.New("validate data error")
-				}
-			}
-
-			if e := model.CallHook("PreDelete", ctx, dm); e != nil {
+			if e := checkKeys(dm); e != nil {
 				return 0, e
 			}
 
-			tx, e := h.BeginTx()
-			if e != nil {
-				tx = h
-			}
-			e = tx.Delete(dm)
-			if e != nil {
-				tx.Rollback()
+			if e := validateFromCtx(ctx, dm); e != nil {
 				return 0, e
 			}
-			tx.Commit()
 
-			if e := model.CallHook("PostDelete", ctx, dm); e != nil {
+			count := 0
+			e := withTx(ctx, h, func(tx *datahub.Hub) error {
+				var e error
+				where := joinFilters(dbflex.And, append([]*dbflex.Filter{idFilter(dm)}, filtersFromCtx(ctx, softDel)...))
+				if count, e = aggregateCount(tx, dm, where); e != nil {
+					return e
+				}
+				if count == 0 {
+					if m.errorOnNotFound {
+						return errNotFound("data not found")
+					}
+					return nil
+				}
+
+				if e = model.CallHook("PreDelete", ctx, dm); e != nil {
+					return e
+				}
+				var before orm.DataModel
+				if isAudited(model) {
+					before = loadCurrent(tx, model, where)
+				}
+				if e = deleteData(tx, dm, softDel); e != nil {
+					return e
+				}
+				if e = m.audit(ctx, tx, model, "delete", dataKeys(dm), before, nil); e != nil {
+					return e
+				}
+				return model.CallHook("PostDelete", ctx, dm)
+			})
+			if e != nil {
 				return 0, e
 			}
-			return 1, nil
+			return count, nil
 		})
 		routes = append(routes, sr)
 	}
@@ -491,31 +554,76 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr.Path = filepath.Join(svc.BasePoint(), alias, "deletequery")
 		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
 		sr.RequestType = reflect.TypeOf(new(dbflex.Filter))
-		sr.ResponseType = reflect.TypeOf(int(0))
-		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, where *dbflex.Filter) (int, error) {
+		sr.ResponseType = reflect.TypeOf(&DeleteQueryResult{})
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, where *dbflex.Filter) (*DeleteQueryResult, error) {
 			h := m.getHub(ctx)
 
 			where = combineFilterFromCtx(where, ctx)
+			if ctxFilters := filtersFromCtx(ctx, softDel); len(ctxFilters) > 0 {
+				where = combineFilter(where, joinFilters(dbflex.And, ctxFilters))
+			}
 			dm := getDataModel(model)
 			if e := model.CallHook("PreDeleteQuery", ctx, where); e != nil {
-				return 0, e
+				return nil, e
 			}
 
-			tx, e := h.BeginTx()
-			if e != nil {
-				tx = h
-			}
-			e = tx.DeleteQuery(dm, where)
-			if e != nil {
-				tx.Rollback()
-				return 0, e
+			// dry run only reports data that would be deleted
+			if isDryRun(ctx) {
+				ids, e := matchedIDs(h, dm, where)
+				if e != nil {
+					return nil, e
+				}
+				return &DeleteQueryResult{Count: len(ids), IDs: ids, DryRun: true}, nil
 			}
-			tx.Commit()
 
-			if e := model.CallHook("PostDeleteQuery", ctx, where); e != nil {
-				return 0, e
+			res := new(DeleteQueryResult)
+			e := withTx(ctx, h, func(tx *datahub.Hub) error {
+				var e error
+				if res.Count, e = aggregateCount(tx, dm, where); e != nil {
+					return e
+				}
+				befores := reflect.New(reflect.SliceOf(rt)).Interface()
+				if isAudited(model) {
+					if e = tx.GetsByFilter(dm, where, befores); e != nil {
+						return e
+					}
+				}
+
+				// executed directly instead of DeleteQuery and UpdateAny to read number of affected data from the driver
+				var (
+					cmd  = dbflex.From(dm.TableName())
+					data interface{}
+				)
+				if softDel != nil {
+					markValues, markFields := softDel.mark(true)
+					cmd.Update(markFields...)
+					data = markValues
+				} else {
+					cmd.Delete()
+				}
+				if where != nil {
+					cmd.Where(where)
+				}
+				executed, e := tx.Execute(cmd, data)
+				if e != nil {
+					return e
+				}
+				if n, ok := affectedRows(executed); ok {
+					res.Count = int(n)
+				}
+				rv := reflect.ValueOf(befores).Elem()
+				for idx := 0; idx < rv.Len(); idx++ {
+					before := rv.Index(idx).Addr().Interface().(orm.DataModel)
+					if e = m.audit(ctx, tx, model, "deletequery", dataKeys(before), before, nil); e != nil {
+						return e
+					}
+				}
+				return model.CallHook("PostDeleteQuery", ctx, where)
+			})
+			if e != nil {
+				return nil, e
 			}
-			return 1, nil
+			return res, nil
 		})
 		routes = append(routes, sr)
 	}
@@ -525,30 +633,157 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		sr = new(kaos.ServiceRoute)
 		sr.Path = filepath.Join(svc.BasePoint(), alias, "deletemany")
 		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
-		// sr.RequestType = reflect.TypeOf(new(dbflex.Filter))
+		sr.RequestType = reflect.SliceOf(keysType)
 		sr.ResponseType = reflect.TypeOf(int(0))
-		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, idValues [][]interface{}) (int, error) {
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, idValues []interface{}) (int, error) {
 			h := m.getHub(ctx)
 
-			for _, idValue := range idValues {
-				dm := getDataModel(model)
-				dm.SetID(idValue...)
-
-				tx, e := h.BeginTx()
+			dms := make([]orm.DataModel, len(idValues))
+			keyValues := make([][]interface{}, len(idValues))
+			for idx, idValue := range idValues {
+				dms[idx] = getDataModel(model)
+				values, e := setKeys(dms[idx], idValue)
 				if e != nil {
-					tx = h
+					return 0, errBadRequest("keys %d: %s", idx, e.Error())
 				}
-				e = tx.Delete(dm)
-				if e != nil {
-					tx.Rollback()
-					return 0, e
+				keyValues[idx] = values
+			}
+
+			deleted := 0
+			e := withTx(ctx, h, func(tx *datahub.Hub) error {
+				for idx, dm := range dms {
+					// only record within context's scope will be deleted
+					where := joinFilters(dbflex.And, append([]*dbflex.Filter{keysFilter(dm, keyValues[idx])}, filtersFromCtx(ctx, softDel)...))
+					count, e := aggregateCount(tx, dm, where)
+					if e != nil {
+						return e
+					}
+					if count == 0 {
+						continue
+					}
+					if e = tx.GetByFilter(dm, where); e != nil {
+						return e
+					}
+
+					if e = model.CallHook("PreDelete", ctx, dm); e != nil {
+						return e
+					}
+					if e = deleteData(tx, dm, softDel); e != nil {
+						return e
+					}
+					if e = m.audit(ctx, tx, model, "deletemany", keyValues[idx], dm, nil); e != nil {
+						return e
+					}
+					if e = model.CallHook("PostDelete", ctx, dm); e != nil {
+						return e
+					}
+					deleted++
 				}
-				tx.Commit()
+				return model.CallHook("PostDeleteMany", ctx, keyValues)
+			})
+			if e != nil {
+				return 0, e
 			}
-			if e := model.CallHook("PostDeleteMany", ctx, idValues); e != nil {
+			return deleted, nil
+		})
+		routes = append(routes, sr)
+	}
+
+	//-- restore, purge
+	if softDel != nil && !codekit.HasMember(disabledRoutes, "restore") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "restore")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = keysType
+		sr.ResponseType = reflect.TypeOf(int(0))
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, keys interface{}) (int, error) {
+			h := m.getHub(ctx)
+			dm := getDataModel(model)
+			values, e := setKeys(dm, keys)
+			if e != nil {
+				return 0, e
+			}
+
+			filters := append([]*dbflex.Filter{keysFilter(dm, values), softDel.deletedFilter()}, filtersFromCtx(ctx, nil)...)
+			where := joinFilters(dbflex.And, filters)
+			count := 0
+			e = withTx(ctx, h, func(tx *datahub.Hub) error {
+				var e error
+				if count, e = aggregateCount(tx, dm, where); e != nil {
+					return e
+				}
+				if count == 0 {
+					if m.errorOnNotFound {
+						return errNotFound("data not found")
+					}
+					return nil
+				}
+				markValues, markFields := softDel.mark(false)
+				return tx.UpdateAny(dm.TableName(), where, markValues, markFields...)
+			})
+			if e != nil {
+				return 0, e
+			}
+			return count, nil
+		})
+		routes = append(routes, sr)
+	}
+
+	if softDel != nil && !codekit.HasMember(disabledRoutes, "purge") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "purge")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = reflect.TypeOf(new(dbflex.Filter))
+		sr.ResponseType = reflect.TypeOf(int(0))
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, where *dbflex.Filter) (int, error) {
+			h := m.getHub(ctx)
+			dm := getDataModel(model)
+
+			// only data marked as deleted can be purged
+			where = combineFilterFromCtx(where, ctx)
+			filters := append([]*dbflex.Filter{softDel.deletedFilter()}, filtersFromCtx(ctx, nil)...)
+			where = combineFilter(where, joinFilters(dbflex.And, filters))
+
+			count := 0
+			e := withTx(ctx, h, func(tx *datahub.Hub) error {
+				var e error
+				if count, e = aggregateCount(tx, dm, where); e != nil {
+					return e
+				}
+				return tx.DeleteQuery(dm, where)
+			})
+			if e != nil {
 				return 0, e
 			}
-			return 1, nil
+			return count, nil
+		})
+		routes = append(routes, sr)
+	}
+
+	//-- history
+	if isAudited(model) && !codekit.HasMember(disabledRoutes, "history") {
+		sr = new(kaos.ServiceRoute)
+		sr.Path = filepath.Join(svc.BasePoint(), alias, "history")
+		sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
+		sr.RequestType = keysType
+		sr.ResponseType = reflect.TypeOf([]*AuditRecord{})
+		sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, keys interface{}) ([]*AuditRecord, error) {
+			h := m.getHub(ctx)
+			dm := getDataModel(model)
+			values, e := setKeys(dm, keys)
+			if e != nil {
+				return nil, e
+			}
+
+			// history of data out of context's scope is not visible, deleted data keeps its history
+			count, e := aggregateCount(h, dm, joinFilters(dbflex.And, append([]*dbflex.Filter{keysFilter(dm, values)}, filtersFromCtx(ctx, nil)...)))
+			if e != nil {
+				return nil, e
+			}
+			if count == 0 {
+				return nil, errNotFound("data not found")
+			}
+			return m.getAuditWriter().ReadAudit(ctx, h, dm.TableName(), auditKeys(values))
 		})
 		routes = append(routes, sr)
 	}
@@ -557,6 +792,7 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 	mdl := reflect.New(rt).Interface().(orm.DataModel)
 	queries := mdl.Queries()
 	for queryName, q := range queries {
+		queryName := queryName
 		getName := fmt.Sprintf("GetBy" + queryName)
 		getsName := fmt.Sprintf("GetsBy" + queryName)
 		findName := fmt.Sprintf("FindBy" + queryName)
@@ -569,10 +805,27 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 			sr.ResponseType = reflect.TypeOf(reflect.PointerTo(rt))
 			sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, param codekit.M) (orm.DataModel, error) {
 				h := m.getHub(ctx)
+				queryParam, scope, e := namedQueryParam(ctx, meta, param)
+				if e != nil {
+					return nil, e
+				}
 
 				dm := getDataModel(model)
-				e := h.GetByQuery(dm, queryName, param)
-				return dm, e
+				if e = h.GetByQuery(dm, queryName, queryParam); e != nil {
+					return dm, e
+				}
+				if e = scope.check(queryName); e != nil {
+					return getDataModel(model), e
+				}
+
+				if e = validateFromCtx(ctx, dm); e != nil {
+					return dm, e
+				}
+				if meta.version != nil {
+					meta.version.setETag(ctx, dm)
+				}
+				model.CallHook("PostGet", ctx, dm)
+				return dm, nil
 			})
 			routes = append(routes, sr)
 		}
@@ -585,22 +838,34 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 			sr.ResponseType = reflect.PointerTo(reflect.SliceOf(rt))
 			sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, param codekit.M) (interface{}, error) {
 				h := m.getHub(ctx)
+				queryParam, scope, e := namedQueryParam(ctx, meta, param)
+				if e != nil {
+					return nil, e
+				}
 
 				mdl := reflect.New(rt).Interface().(orm.DataModel)
 				dest := reflect.New(reflect.SliceOf(rt)).Interface()
+				if e = h.GetsByQuery(mdl, queryName, queryParam, dest); e != nil {
+					return nil, e
+				}
+				if e = scope.check(queryName); e != nil {
+					return nil, e
+				}
 
-				e := h.GetsByQuery(mdl, queryName, param, dest)
+				connIndex, conn, e := h.GetConnection()
 				if e != nil {
 					return nil, e
 				}
-
-				connIndex, conn, _ := h.GetConnection()
 				defer h.CloseConnection(connIndex, conn)
-				recordCount, _ := orm.CountQuery(conn, mdl, queryName, param)
+				countParam, counted := countScope(queryParam, scope)
+				recordCount, _ := orm.CountQuery(conn, mdl, queryName, countParam)
+				if e = counted.check(queryName); e != nil {
+					return nil, e
+				}
 
-				m := codekit.M{}.Set("data", dest).Set("count", recordCount)
-				model.CallHook("PostGets", ctx, m)
-				return m, nil
+				res := codekit.M{}.Set("data", dest).Set("count", recordCount)
+				model.CallHook("PostGets", ctx, res)
+				return res, nil
 			})
 			routes = append(routes, sr)
 		}
@@ -611,14 +876,20 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 			sr.Path = strings.Replace(sr.Path, "\\", "/", -1)
 			sr.RequestType = reflect.TypeOf(codekit.M{})
 			sr.ResponseType = reflect.PointerTo(reflect.SliceOf(rt))
-			sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, parm codekit.M) (interface{}, error) {
+			sr.Fn = reflect.ValueOf(func(ctx *kaos.Context, param codekit.M) (interface{}, error) {
 				h := m.getHub(ctx)
+				queryParam, scope, e := namedQueryParam(ctx, meta, param)
+				if e != nil {
+					return nil, e
+				}
 
 				mdl := reflect.New(rt).Interface().(orm.DataModel)
 				dest := reflect.New(reflect.SliceOf(rt)).Interface()
 				// get data
-				e := h.GetsByQuery(mdl, queryName, parm, dest)
-				if e != nil {
+				if e = h.GetsByQuery(mdl, queryName, queryParam, dest); e != nil {
+					return nil, e
+				}
+				if e = scope.check(queryName); e != nil {
 					return nil, e
 				}
 				model.CallHook("PostFind", ctx, dest)
@@ -628,6 +899,10 @@ func (m *mod) MakeModelRoute(svc *kaos.Service, model *kaos.ServiceModel) ([]*ka
 		}
 	}
 
+	for _, sr := range routes {
+		sr.Fn = m.routeMWFn(model, path.Base(sr.Path), sr.Fn)
+		sr.Fn = m.tenantFn(meta, sr.Fn)
+	}
 	return routes, nil
 }
 
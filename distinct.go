@@ -0,0 +1,131 @@
+package dbmod
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+// DistinctValue is a value of distinct route and number of data having it
+type DistinctValue struct {
+	Value interface{}
+	Count int
+}
+
+// distinctData returns sorted unique values of a field within scope of the context,
+// as []*DistinctValue when WithCount is set or []interface{} otherwise
+func (m *mod) distinctData(ctx *kaos.Context, h *datahub.Hub, model *kaos.ServiceModel, payload *DistinctRequest) (interface{}, error) {
+	if payload == nil || payload.Field == "" {
+		return nil, errBadRequest("field is required")
+	}
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return nil, e
+	}
+
+	name, ft := lookupField(meta.fields, payload.Field)
+	if ft == nil {
+		return nil, errBadRequest("unknown field %s", payload.Field)
+	}
+	if !meta.policy.CanFilter(name) {
+		return nil, errBadRequest("field %s is not filterable", name)
+	}
+	if e = meta.policy.checkFilter(payload.Where); e != nil {
+		return nil, e
+	}
+
+	dm := getDataModel(model)
+	where := combineFilterFromCtx(payload.Where, ctx)
+	if ctxFilters := filtersFromCtx(ctx, meta.softDelete); len(ctxFilters) > 0 {
+		where = combineFilter(where, joinFilters(dbflex.And, ctxFilters))
+	}
+
+	qp := dbflex.NewQueryParam()
+	qp.Where = where
+	qp.GroupBy = []string{name}
+	qp.Aggregates = []*dbflex.AggrItem{{Field: name, Op: "$count", Alias: "Count"}}
+	rows := []codekit.M{}
+	if e = h.PopulateByParm(dm.TableName(), qp, &rows); e != nil {
+		return nil, e
+	}
+
+	values := make([]*DistinctValue, len(rows))
+	for idx, row := range rows {
+		value, ok := row[name]
+		if !ok {
+			// some drivers keep group keys in _id
+			if idM, isMap := asMap(row["_id"]); isMap {
+				value = idM[name]
+			}
+		}
+		values[idx] = &DistinctValue{Value: value, Count: row.GetInt("Count")}
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return compareValues(values[i].Value, values[j].Value) < 0
+	})
+
+	if payload.WithCount {
+		return values, nil
+	}
+	res := make([]interface{}, len(values))
+	for idx, value := range values {
+		res[idx] = value.Value
+	}
+	return res, nil
+}
+
+// compareValues compares numbers, times and others as string, nil is less than any value
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package dbmod
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error is returned by generated routes when request can not be served, Code follows http status code
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusCode returns http status code of the error
+func (e *Error) StatusCode() int {
+	return e.Code
+}
+
+func newError(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func errBadRequest(format string, args ...interface{}) error {
+	return newError(http.StatusBadRequest, format, args...)
+}
+
+func errNotFound(format string, args ...interface{}) error {
+	return newError(http.StatusNotFound, format, args...)
+}
+
+func errForbidden(format string, args ...interface{}) error {
+	return newError(http.StatusForbidden, format, args...)
+}
+
+func errConflict(format string, args ...interface{}) error {
+	return newError(http.StatusConflict, format, args...)
+}
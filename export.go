@@ -0,0 +1,263 @@
+package dbmod
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+/*
+ExportWriterTag is context key of io.Writer where export route writes the rows, http_writer is used when it is not set.
+Keys of dbflex.QueryParam.Param of export route:
+  - ExportFormatParam: ExportCSV (default) or ExportNDJSON
+  - ExportColumnsParam: fields to be exported, default is all fields which are not hidden
+  - ExportHeadersParam: labels of the columns, a list following the columns or a map of field and its label
+*/
+const ExportWriterTag = "mdb_export_writer"
+
+const (
+	ExportFormatParam  = "format"
+	ExportColumnsParam = "columns"
+	ExportHeadersParam = "headers"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// exportPageSize is number of rows read from database at once
+const exportPageSize = 500
+
+type exportColumn struct {
+	field string
+	label string
+	index []int
+}
+
+// exportData writes all rows matching the query page by page and returns number of exported rows
+func (m *mod) exportData(ctx *kaos.Context, h *datahub.Hub, model *kaos.ServiceModel, payload *dbflex.QueryParam) (int, error) {
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return 0, e
+	}
+	parm, e := scopedQueryParam(ctx, meta, payload)
+	if e != nil {
+		return 0, e
+	}
+	if parm.Param == nil {
+		parm.Param = codekit.M{}
+	}
+
+	format := parm.Param.GetString(ExportFormatParam)
+	if format == "" {
+		format = ExportCSV
+	}
+	if format != ExportCSV && format != ExportNDJSON {
+		return 0, errBadRequest("unknown export format %s", format)
+	}
+	columns, e := exportColumns(meta, parm)
+	if e != nil {
+		return 0, e
+	}
+
+	w, ok := ctx.Data().Get(ExportWriterTag, nil).(io.Writer)
+	if !ok {
+		hw, isHttp := ctx.Data().Get("http_writer", nil).(http.ResponseWriter)
+		if !isHttp {
+			return 0, fmt.Errorf("export needs a writer, set %s on context", ExportWriterTag)
+		}
+		mdl := getDataModel(model)
+		if format == ExportCSV {
+			hw.Header().Set("Content-Type", "text/csv")
+		} else {
+			hw.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		hw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", mdl.TableName()+"."+format))
+		w = hw
+	}
+
+	var csvWriter *csv.Writer
+	if format == ExportCSV {
+		csvWriter = csv.NewWriter(w)
+		labels := make([]string, len(columns))
+		for idx, col := range columns {
+			labels[idx] = col.label
+		}
+		if e = csvWriter.Write(labels); e != nil {
+			return 0, e
+		}
+	}
+	jsonEncoder := json.NewEncoder(w)
+
+	// pages are read by keyset of the last row so no row is skipped or repeated when data changes during export
+	mdl := getDataModel(model)
+	idFields, _ := mdl.GetID(nil)
+	parm.Sort = keysetSort(parm.Sort, idFields)
+	parm.Select = nil
+	limit, where := parm.Take, parm.Where
+
+	exported := 0
+	for limit == 0 || exported < limit {
+		parm.Take = exportPageSize
+		if limit > 0 && limit-exported < exportPageSize {
+			parm.Take = limit - exported
+		}
+
+		dest := reflect.New(reflect.SliceOf(model.ModelType))
+		if e = h.Gets(mdl, parm, dest.Interface()); e != nil {
+			return exported, e
+		}
+		rows := dest.Elem()
+		for idx := 0; idx < rows.Len(); idx++ {
+			row := rows.Index(idx)
+			if csvWriter != nil {
+				e = csvWriter.Write(exportCSVRecord(columns, row))
+			} else {
+				e = jsonEncoder.Encode(exportJSONRecord(columns, row))
+			}
+			if e != nil {
+				return exported, e
+			}
+			exported++
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if e = csvWriter.Error(); e != nil {
+				return exported, e
+			}
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if rows.Len() < parm.Take {
+			break
+		}
+
+		cursor, e := encodeCursor(meta.fields, parm.Sort, rows.Index(rows.Len()-1))
+		if e != nil {
+			return exported, e
+		}
+		after, e := decodeCursor(meta.fields, parm.Sort, cursor)
+		if e != nil {
+			return exported, e
+		}
+		parm.Where = after
+		if where != nil {
+			parm.Where = dbflex.And(where, after)
+		}
+		parm.Skip = 0
+	}
+	return exported, nil
+}
+
+// exportColumns returns requested columns, they should be known, not hidden and selected by DbModSelect if it is set
+func exportColumns(meta *modelMeta, parm *dbflex.QueryParam) ([]*exportColumn, error) {
+	allowed := func(field string) bool {
+		return meta.policy.CanView(field) && (len(parm.Select) == 0 || hasFieldName(parm.Select, field))
+	}
+
+	names := []string{}
+	if requested, ok := parm.Param.Get(ExportColumnsParam, nil).([]interface{}); ok {
+		for _, r := range requested {
+			names = append(names, fmt.Sprintf("%v", r))
+		}
+	} else if requested, ok := parm.Param.Get(ExportColumnsParam, nil).([]string); ok {
+		names = requested
+	} else {
+		for name := range meta.fields {
+			if allowed(name) {
+				names = append(names, name)
+			}
+		}
+		// follow order of fields in the struct
+		sort.Slice(names, func(i, j int) bool {
+			a, b := meta.fields[names[i]].Index, meta.fields[names[j]].Index
+			for idx := 0; idx < len(a) && idx < len(b); idx++ {
+				if a[idx] != b[idx] {
+					return a[idx] < b[idx]
+				}
+			}
+			return len(a) < len(b)
+		})
+	}
+	if len(names) == 0 {
+		return nil, errBadRequest("no column to export")
+	}
+
+	headers := parm.Param.Get(ExportHeadersParam, nil)
+	headerList, _ := headers.([]interface{})
+	headerMap, _ := asMap(headers)
+
+	columns := make([]*exportColumn, len(names))
+	for idx, name := range names {
+		field, ft := lookupField(meta.fields, name)
+		if ft == nil {
+			return nil, errBadRequest("unknown field %s", name)
+		}
+		if !allowed(field) {
+			return nil, errBadRequest("field %s can not be exported", field)
+		}
+		col := &exportColumn{field: field, label: field, index: meta.fields[field].Index}
+		if idx < len(headerList) {
+			col.label = fmt.Sprintf("%v", headerList[idx])
+		} else if label, ok := keyValue(headerMap, field); ok {
+			col.label = fmt.Sprintf("%v", label)
+		}
+		columns[idx] = col
+	}
+	return columns, nil
+}
+
+func exportValue(row reflect.Value, col *exportColumn) interface{} {
+	fv, err := reflect.Indirect(row).FieldByIndexErr(col.index)
+	if err != nil {
+		return nil
+	}
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return nil
+	}
+	return fv.Interface()
+}
+
+func exportCSVRecord(columns []*exportColumn, row reflect.Value) []string {
+	record := make([]string, len(columns))
+	for idx, col := range columns {
+		switch v := exportValue(row, col).(type) {
+		case nil:
+		case string:
+			record[idx] = v
+		case time.Time:
+			record[idx] = v.Format(time.RFC3339)
+		case *time.Time:
+			record[idx] = v.Format(time.RFC3339)
+		default:
+			switch reflect.Indirect(reflect.ValueOf(v)).Kind() {
+			case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+				bs, _ := json.Marshal(v)
+				record[idx] = string(bs)
+			default:
+				record[idx] = fmt.Sprintf("%v", reflect.Indirect(reflect.ValueOf(v)).Interface())
+			}
+		}
+	}
+	return record
+}
+
+func exportJSONRecord(columns []*exportColumn, row reflect.Value) codekit.M {
+	record := codekit.M{}
+	for _, col := range columns {
+		record.Set(col.label, exportValue(row, col))
+	}
+	return record
+}
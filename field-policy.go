@@ -0,0 +1,161 @@
+package dbmod
+
+import (
+	"reflect"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex"
+)
+
+/*
+FieldPolicyTag marks which model fields client may filter and sort on, value is comma separated of:
+filter, sort (whitelist the field), nofilter, nosort (blacklist the field), - (nofilter and nosort)
+and hidden (the field is never exported).
+Once a field is whitelisted, other fields are not allowed for the respective operation
+*/
+const FieldPolicyTag = "mdb_field"
+
+// FieldPolicy lists fields that can and can not be used by client to filter and sort, and fields hidden from export.
+// Empty Filterable or Sortable means all fields are allowed unless blacklisted
+type FieldPolicy struct {
+	Filterable    []string
+	NonFilterable []string
+	Sortable      []string
+	NonSortable   []string
+	Hidden        []string
+}
+
+// FieldPolicyModel can be implemented by orm.DataModel to declare its FieldPolicy,
+// it is merged with the one declared by FieldPolicyTag
+type FieldPolicyModel interface {
+	FieldPolicy() *FieldPolicy
+}
+
+func newFieldPolicy(fields map[string]reflect.StructField, mdl interface{}) *FieldPolicy {
+	p := new(FieldPolicy)
+	for name, f := range fields {
+		tag, ok := f.Tag.Lookup(FieldPolicyTag)
+		if !ok {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			switch strings.TrimSpace(rule) {
+			case "filter":
+				p.Filterable = append(p.Filterable, name)
+			case "sort":
+				p.Sortable = append(p.Sortable, name)
+			case "nofilter":
+				p.NonFilterable = append(p.NonFilterable, name)
+			case "nosort":
+				p.NonSortable = append(p.NonSortable, name)
+			case "-":
+				p.NonFilterable = append(p.NonFilterable, name)
+				p.NonSortable = append(p.NonSortable, name)
+			case "hidden":
+				p.Hidden = append(p.Hidden, name)
+			}
+		}
+	}
+
+	if pm, ok := mdl.(FieldPolicyModel); ok {
+		if other := pm.FieldPolicy(); other != nil {
+			p.Filterable = append(p.Filterable, other.Filterable...)
+			p.NonFilterable = append(p.NonFilterable, other.NonFilterable...)
+			p.Sortable = append(p.Sortable, other.Sortable...)
+			p.NonSortable = append(p.NonSortable, other.NonSortable...)
+			p.Hidden = append(p.Hidden, other.Hidden...)
+		}
+	}
+	return p
+}
+
+// CanFilter returns true if client may use the field on filter
+func (p *FieldPolicy) CanFilter(field string) bool {
+	if p == nil {
+		return true
+	}
+	return fieldAllowed(field, p.Filterable, p.NonFilterable)
+}
+
+// CanSort returns true if client may use the field on sort
+func (p *FieldPolicy) CanSort(field string) bool {
+	if p == nil {
+		return true
+	}
+	return fieldAllowed(field, p.Sortable, p.NonSortable)
+}
+
+// CanView returns false if the field is hidden from client
+func (p *FieldPolicy) CanView(field string) bool {
+	if p == nil {
+		return true
+	}
+	return fieldAllowed(field, nil, p.Hidden)
+}
+
+func fieldAllowed(field string, allows, denies []string) bool {
+	names := []string{field}
+	if idx := strings.Index(field, "."); idx > 0 {
+		names = append(names, field[:idx])
+	}
+
+	for _, name := range names {
+		if hasFieldName(denies, name) {
+			return false
+		}
+	}
+
+	if len(allows) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if hasFieldName(allows, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFieldName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *FieldPolicy) checkFilter(f *dbflex.Filter) error {
+	if f == nil {
+		return nil
+	}
+	for _, item := range f.Items {
+		if e := p.checkFilter(item); e != nil {
+			return e
+		}
+	}
+	if f.Field != "" && !p.CanFilter(f.Field) {
+		return errBadRequest("field %s is not filterable", f.Field)
+	}
+	return nil
+}
+
+func (p *FieldPolicy) checkSort(sorts []string) error {
+	for _, sort := range sorts {
+		field := strings.TrimLeft(strings.TrimSpace(sort), "-+")
+		if !p.CanSort(field) {
+			return errBadRequest("field %s is not sortable", field)
+		}
+	}
+	return nil
+}
+
+func (p *FieldPolicy) checkQueryParam(qp *dbflex.QueryParam) error {
+	if qp == nil {
+		return nil
+	}
+	if e := p.checkFilter(qp.Where); e != nil {
+		return e
+	}
+	return p.checkSort(qp.Sort)
+}
@@ -0,0 +1,36 @@
+package dbmod
+
+import "testing"
+
+func TestFieldAllowed(t *testing.T) {
+	cases := []struct {
+		name   string
+		field  string
+		allows []string
+		denies []string
+		want   bool
+	}{
+		{name: "no policy", field: "Name", want: true},
+		{name: "allowed", field: "Name", allows: []string{"Name"}, want: true},
+		{name: "not allowed", field: "Age", allows: []string{"Name"}, want: false},
+		{name: "denied", field: "Secret", denies: []string{"Secret"}, want: false},
+		{name: "deny wins", field: "Name", allows: []string{"Name"}, denies: []string{"Name"}, want: false},
+		{name: "case insensitive", field: "name", allows: []string{"Name"}, want: true},
+		{name: "sub field of allowed", field: "Address.City", allows: []string{"Address"}, want: true},
+		{name: "sub field of denied", field: "Secret.Key", denies: []string{"Secret"}, want: false},
+		{name: "allowed sub field only", field: "Address", allows: []string{"Address.City"}, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := fieldAllowed(c.field, c.allows, c.denies); got != c.want {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	var p *FieldPolicy
+	if !p.CanFilter("Name") || !p.CanSort("Name") || !p.CanView("Name") {
+		t.Fatal("nil policy allows every field")
+	}
+}
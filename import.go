@@ -0,0 +1,306 @@
+package dbmod
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+)
+
+// ImportReaderTag is context key of io.Reader to be read by import route when Data of the request is empty
+const ImportReaderTag = "mdb_import_reader"
+
+// importBatchSize is default number of rows written in a transaction
+const importBatchSize = 100
+
+// ImportError is error of a line of import data, Fields is set when it is a validation error
+type ImportError struct {
+	Line   int
+	Error  string
+	Fields []*FieldError `json:",omitempty"`
+}
+
+// ImportResult is result of import route, Imported is 0 on dry run
+type ImportResult struct {
+	Total    int
+	Valid    int
+	Imported int
+	DryRun   bool
+	Errors   []*ImportError
+}
+
+type importRow struct {
+	line int
+	data map[string]interface{}
+	dm   orm.DataModel
+}
+
+// errImportDryRun rolls back transaction of dry run
+var errImportDryRun = errors.New("import dry run")
+
+// importData reads rows of the request and writes them in batches, each batch is a transaction.
+// A row failing validation or pre hook is reported and skipped. When writing a row fails, it is reported and
+// the batch is written again without it in a new transaction. Without transaction support rows written before
+// the failed one are kept and counted as imported, the rest of the batch continues
+func (m *mod) importData(ctx *kaos.Context, h *datahub.Hub, model *kaos.ServiceModel, payload *ImportRequest) (*ImportResult, error) {
+	if payload == nil {
+		payload = new(ImportRequest)
+	}
+	op := payload.Op
+	if op == "" {
+		op = writeSave
+	}
+	if op != writeSave && op != writeInsert {
+		return nil, errBadRequest("import op should be %s or %s", writeSave, writeInsert)
+	}
+	batchSize := payload.BatchSize
+	if batchSize <= 0 {
+		batchSize = importBatchSize
+	}
+
+	var r io.Reader = strings.NewReader(payload.Data)
+	if payload.Data == "" {
+		reader, ok := ctx.Data().Get(ImportReaderTag, nil).(io.Reader)
+		if !ok {
+			return nil, errBadRequest("import data is empty")
+		}
+		r = reader
+	}
+
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return nil, e
+	}
+
+	res := &ImportResult{DryRun: isDryRun(ctx), Errors: []*ImportError{}}
+	addError := func(line int, e error) {
+		res.Errors = append(res.Errors, &ImportError{Line: line, Error: e.Error(), Fields: ValidationErrors(e)})
+	}
+
+	var rows []*importRow
+	switch format := strings.ToLower(payload.Format); format {
+	case "", ExportCSV:
+		rows, e = readImportCSV(meta, r, payload.Headers, addError)
+	case ExportNDJSON:
+		rows, e = readImportNDJSON(r, payload.Headers, addError)
+	default:
+		return nil, errBadRequest("unknown import format %s", payload.Format)
+	}
+	if e != nil {
+		return nil, e
+	}
+	res.Total = len(rows) + len(res.Errors)
+
+	valid := []*importRow{}
+	for _, row := range rows {
+		if row.dm, e = decodePatched(model, row.data); e != nil {
+			addError(row.line, e)
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	// dry run prepares rows in a transaction which is always rolled back, so hooks can read but not keep any change.
+	// It is refused when the hub has no transaction, as changes made by hooks could not be rolled back
+	if res.DryRun {
+		e = withTx(ctx, h, func(tx *datahub.Hub) error {
+			if !tx.IsTx() {
+				return newError(http.StatusNotImplemented, "dry run of import needs a hub with transaction")
+			}
+			for _, row := range valid {
+				if e := m.prepareData(ctx, model, row.dm, op); e != nil {
+					addError(row.line, e)
+				}
+			}
+			return errImportDryRun
+		})
+		if e != nil && !errors.Is(e, errImportDryRun) {
+			return nil, e
+		}
+		res.Valid = res.Total - len(res.Errors)
+		return res, nil
+	}
+
+	for start := 0; start < len(valid); start += batchSize {
+		end := start + batchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		batch := valid[start:end]
+
+		for len(batch) > 0 {
+			stored, failed, inTx := []*importRow{}, -1, false
+			e = withTx(ctx, h, func(tx *datahub.Hub) error {
+				inTx = tx.IsTx()
+				for idx, row := range batch {
+					// decoded again from the row, so a replayed row does not carry changes of the rolled back attempt
+					dm, e := decodePatched(model, row.data)
+					if e != nil {
+						addError(row.line, e)
+						continue
+					}
+					if e := m.prepareData(ctx, model, dm, op); e != nil {
+						addError(row.line, e)
+						continue
+					}
+					if e := m.storeData(ctx, tx, model, dm, op); e != nil {
+						failed = idx
+						return e
+					}
+					stored = append(stored, row)
+				}
+				return nil
+			})
+			if e == nil || !inTx {
+				res.Imported += len(stored)
+			} else if failed < 0 {
+				for _, row := range stored {
+					addError(row.line, e)
+				}
+			}
+			if failed < 0 {
+				break
+			}
+
+			// the failed row is dropped, rows rolled back with it are written again with the rest of the batch
+			addError(batch[failed].line, e)
+			rest := batch[failed+1:]
+			if !inTx {
+				stored = nil
+			}
+			batch = append(stored, rest...)
+		}
+	}
+	res.Valid = res.Total - len(res.Errors)
+	return res, nil
+}
+
+// readImportCSV reads CSV with header, header is a field name or a label mapped to field by headers
+func readImportCSV(meta *modelMeta, r io.Reader, headers map[string]string, addError func(int, error)) ([]*importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, e := reader.Read()
+	if e != nil {
+		return nil, errBadRequest("invalid csv header: %s", e.Error())
+	}
+
+	columns := make([]string, len(header))
+	for idx, label := range header {
+		label = strings.TrimSpace(label)
+		field, ft := lookupField(meta.fields, importField(headers, label))
+		if ft == nil {
+			return nil, errBadRequest("unknown column %s", label)
+		}
+		columns[idx] = field
+	}
+
+	rows := []*importRow{}
+	for {
+		record, e := reader.Read()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			var pe *csv.ParseError
+			if !errors.As(e, &pe) {
+				return nil, e
+			}
+			addError(pe.Line, e)
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(columns) {
+			addError(line, fmt.Errorf("expecting %d columns, got %d", len(columns), len(record)))
+			continue
+		}
+
+		row := &importRow{line: line, data: map[string]interface{}{}}
+		for idx, value := range record {
+			if value == "" {
+				continue
+			}
+			v, err := importValue(meta.fields[columns[idx]].Type, value)
+			if err != nil {
+				e = fmt.Errorf("invalid value of %s: %s", columns[idx], err.Error())
+				break
+			}
+			row.data[columns[idx]] = v
+		}
+		if e != nil {
+			addError(line, e)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importField returns field of a label mapped by headers, the label itself when it is not mapped
+func importField(headers map[string]string, label string) string {
+	for headerLabel, field := range headers {
+		if strings.EqualFold(headerLabel, label) {
+			return field
+		}
+	}
+	return label
+}
+
+func readImportNDJSON(r io.Reader, headers map[string]string, addError func(int, error)) ([]*importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	rows := []*importRow{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		data := map[string]interface{}{}
+		if e := json.Unmarshal([]byte(text), &data); e != nil {
+			addError(line, fmt.Errorf("invalid json: %s", e.Error()))
+			continue
+		}
+		if len(headers) > 0 {
+			mapped := make(map[string]interface{}, len(data))
+			for key, value := range data {
+				mapped[importField(headers, key)] = value
+			}
+			data = mapped
+		}
+		rows = append(rows, &importRow{line: line, data: data})
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	return rows, nil
+}
+
+// importValue converts csv value into type of the field, struct, map and slice are written as json by export route
+func importValue(ft reflect.Type, s string) (interface{}, error) {
+	kt := ft
+	for kt.Kind() == reflect.Ptr {
+		kt = kt.Elem()
+	}
+	switch kt.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if kt == timeType {
+			break
+		}
+		var v interface{}
+		if e := json.Unmarshal([]byte(s), &v); e != nil {
+			return nil, e
+		}
+		return v, nil
+	}
+	return parseFieldValue(ft, s)
+}
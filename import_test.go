@@ -0,0 +1,82 @@
+package dbmod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportValue(t *testing.T) {
+	fields := modelFields(reflect.TypeOf(testModel{}))
+	cases := []struct {
+		field string
+		value string
+		want  interface{}
+		err   bool
+	}{
+		{field: "Name", value: "a", want: "a"},
+		{field: "Age", value: "18", want: 18},
+		{field: "Age", value: "x", err: true},
+		{field: "Score", value: "1.5", want: 1.5},
+		{field: "Active", value: "true", want: true},
+		{field: "Joined", value: "2024-01-02", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{field: "Tags", value: `["a","b"]`, want: []interface{}{"a", "b"}},
+		{field: "Tags", value: "a,b", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.field+"="+c.value, func(t *testing.T) {
+			got, err := importValue(fields[c.field].Type, c.value)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expecting error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestReadImportCSV(t *testing.T) {
+	meta := &modelMeta{fields: modelFields(reflect.TypeOf(testModel{}))}
+	data := strings.Join([]string{
+		"Full Name,age,Active",
+		"a,18,true",
+		"b,x,false",
+		"c,20",
+		"d,,",
+	}, "\n")
+
+	errLines := []int{}
+	addError := func(line int, e error) {
+		errLines = append(errLines, line)
+	}
+	rows, err := readImportCSV(meta, strings.NewReader(data), map[string]string{"full name": "Name"}, addError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(errLines, []int{3, 4}) {
+		t.Fatalf("error lines: got %v, want [3 4]", errLines)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expecting 2 rows, got %d", len(rows))
+	}
+	if rows[0].line != 2 || !reflect.DeepEqual(rows[0].data, map[string]interface{}{"Name": "a", "Age": 18, "Active": true}) {
+		t.Fatalf("row 0: got line %d %v", rows[0].line, rows[0].data)
+	}
+	if rows[1].line != 5 || !reflect.DeepEqual(rows[1].data, map[string]interface{}{"Name": "d"}) {
+		t.Fatalf("row 1: got line %d %v", rows[1].line, rows[1].data)
+	}
+
+	if _, err = readImportCSV(meta, strings.NewReader("Name,Unknown\na,b"), nil, addError); err == nil {
+		t.Fatal("expecting error of unknown column")
+	}
+}
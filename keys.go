@@ -0,0 +1,169 @@
+package dbmod
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"github.com/sebarcode/codekit"
+)
+
+// keysType is request type of routes receiving keys, either a slice or a map
+var keysType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+/*
+parseKeys resolves keys sent by client into values of id fields of the model.
+keys is either positional values following order of GetID, a map of id field name and its value,
+or a single value when the model has only one id field
+*/
+func parseKeys(dm orm.DataModel, keys interface{}) ([]interface{}, error) {
+	idFields, _ := dm.GetID(nil)
+	if keys == nil {
+		return nil, errBadRequest("keys are required: %s", strings.Join(idFields, ", "))
+	}
+
+	if keyMap, ok := asMap(keys); ok {
+		for name := range keyMap {
+			if !hasFieldName(idFields, name) {
+				return nil, errBadRequest("unknown key %s", name)
+			}
+		}
+		values := make([]interface{}, len(idFields))
+		for idx, idField := range idFields {
+			value, ok := keyValue(keyMap, idField)
+			if !ok {
+				return nil, errBadRequest("missing key %s", idField)
+			}
+			values[idx] = value
+		}
+		return coerceKeys(dm, idFields, values)
+	}
+
+	rv := reflect.ValueOf(keys)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		if len(idFields) != 1 {
+			return nil, errBadRequest("expecting %d keys: %s", len(idFields), strings.Join(idFields, ", "))
+		}
+		return coerceKeys(dm, idFields, []interface{}{keys})
+	}
+
+	values := make([]interface{}, rv.Len())
+	for idx := range values {
+		values[idx] = rv.Index(idx).Interface()
+	}
+	if len(values) < len(idFields) {
+		return nil, errBadRequest("missing key %s", strings.Join(idFields[len(values):], ", "))
+	}
+	if len(values) > len(idFields) {
+		return nil, errBadRequest("expecting %d keys: %s, got %d", len(idFields), strings.Join(idFields, ", "), len(values))
+	}
+	return coerceKeys(dm, idFields, values)
+}
+
+// coerceKeys converts keys into type of their id field, ie number decoded from JSON as float64 into int
+func coerceKeys(dm orm.DataModel, idFields []string, values []interface{}) ([]interface{}, error) {
+	fields := modelFields(reflect.TypeOf(dm))
+	for idx, idField := range idFields {
+		_, ft := lookupField(fields, idField)
+		value, err := coerceKey(ft, values[idx])
+		if err != nil {
+			return nil, errBadRequest("invalid key %s: %s", idField, err.Error())
+		}
+		values[idx] = value
+	}
+	return values, nil
+}
+
+func coerceKey(ft reflect.Type, value interface{}) (interface{}, error) {
+	if ft == nil || value == nil {
+		return value, nil
+	}
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(ft) {
+		return value, nil
+	}
+
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		s = v.String()
+	default:
+		if rv.Kind() == ft.Kind() && rv.Type().ConvertibleTo(ft) {
+			return rv.Convert(ft).Interface(), nil
+		}
+		return value, nil
+	}
+	parsed, err := parseFieldValue(ft, s)
+	if err != nil {
+		return nil, err
+	}
+	if pv := reflect.ValueOf(parsed); pv.Type().ConvertibleTo(ft) {
+		return pv.Convert(ft).Interface(), nil
+	}
+	return parsed, nil
+}
+
+// setKeys sets id of data from keys sent by client and returns the parsed keys, see parseKeys
+func setKeys(dm orm.DataModel, keys interface{}) ([]interface{}, error) {
+	values, e := parseKeys(dm, keys)
+	if e != nil {
+		return nil, e
+	}
+	dm.SetID(values...)
+	return values, nil
+}
+
+// keysFilter returns filter of id fields of the model with values returned by parseKeys
+func keysFilter(dm orm.DataModel, values []interface{}) *dbflex.Filter {
+	idFields, _ := dm.GetID(nil)
+	filters := make([]*dbflex.Filter, len(idFields))
+	for idx, idField := range idFields {
+		filters[idx] = dbflex.Eq(idField, values[idx])
+	}
+	return joinFilters(dbflex.And, filters)
+}
+
+// keysOf returns id fields found in data as a key map
+func keysOf(dm orm.DataModel, data codekit.M) codekit.M {
+	idFields, _ := dm.GetID(nil)
+	res := codekit.M{}
+	for _, idField := range idFields {
+		if value, ok := keyValue(data, idField); ok {
+			res.Set(idField, value)
+		}
+	}
+	return res
+}
+
+// checkKeys makes sure every id field of data is present, zero number is a valid key but empty string is not
+func checkKeys(dm orm.DataModel) error {
+	idFields, values := dm.GetID(nil)
+	for idx, idField := range idFields {
+		if idx >= len(values) || values[idx] == nil || values[idx] == "" {
+			return errBadRequest("missing key %s", idField)
+		}
+	}
+	return nil
+}
+
+func keyValue(data map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := data[name]; ok {
+		return value, true
+	}
+	for key, value := range data {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,91 @@
+package dbmod
+
+import (
+	"reflect"
+	"testing"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"github.com/sebarcode/codekit"
+)
+
+func TestParseKeys(t *testing.T) {
+	cases := []struct {
+		name string
+		dm   orm.DataModel
+		keys interface{}
+		want []interface{}
+		err  bool
+	}{
+		{name: "single value", dm: new(testModel), keys: "a", want: []interface{}{"a"}},
+		{name: "single list", dm: new(testModel), keys: []interface{}{"a"}, want: []interface{}{"a"}},
+		{name: "single map", dm: new(testModel), keys: map[string]interface{}{"_id": "a"}, want: []interface{}{"a"}},
+		{name: "map is case insensitive", dm: new(testModel), keys: codekit.M{"_ID": "a"}, want: []interface{}{"a"}},
+		{name: "bytes are single value", dm: new(testModel), keys: []byte("a"), want: []interface{}{[]byte("a")}},
+		{name: "nil", dm: new(testModel), keys: nil, err: true},
+		{name: "unknown key", dm: new(testModel), keys: codekit.M{"_id": "a", "Name": "b"}, err: true},
+		{name: "too many", dm: new(testModel), keys: []interface{}{"a", "b"}, err: true},
+		{name: "composite list", dm: new(testKeyModel), keys: []interface{}{2024, 1}, want: []interface{}{2024, 1}},
+		{name: "composite map follows id order", dm: new(testKeyModel), keys: codekit.M{"No": 1, "Year": 2024},
+			want: []interface{}{2024, 1}},
+		{name: "composite missing in list", dm: new(testKeyModel), keys: []interface{}{2024}, err: true},
+		{name: "composite missing in map", dm: new(testKeyModel), keys: codekit.M{"Year": 2024}, err: true},
+		{name: "composite single value", dm: new(testKeyModel), keys: 2024, err: true},
+		{name: "composite too many", dm: new(testKeyModel), keys: []interface{}{2024, 1, 2}, err: true},
+		{name: "json numbers", dm: new(testKeyModel), keys: []interface{}{float64(2024), float64(0)}, want: []interface{}{2024, 0}},
+		{name: "string number", dm: new(testKeyModel), keys: codekit.M{"Year": "2024", "No": 1}, want: []interface{}{2024, 1}},
+		{name: "fraction", dm: new(testKeyModel), keys: []interface{}{2024.5, 1}, err: true},
+		{name: "not a number", dm: new(testKeyModel), keys: []interface{}{"x", 1}, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseKeys(c.dm, c.keys)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expecting error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSetKeys(t *testing.T) {
+	dm := new(testKeyModel)
+	values, err := setKeys(dm, []interface{}{float64(2024), float64(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dm.Year != 2024 || dm.No != 0 {
+		t.Fatalf("id is not set: %v %v", dm.Year, dm.No)
+	}
+
+	where := keysFilter(dm, values)
+	if where.Op != dbflex.OpAnd || len(where.Items) != 2 {
+		t.Fatalf("expecting and of 2 keys, got %v", where)
+	}
+	for idx, field := range []string{"Year", "No"} {
+		if where.Items[idx].Field != field || where.Items[idx].Value != values[idx] {
+			t.Fatalf("filter %d: got %s %v", idx, where.Items[idx].Field, where.Items[idx].Value)
+		}
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	if err := checkKeys(&testKeyModel{Year: 2024}); err != nil {
+		t.Fatalf("zero key is valid: %s", err.Error())
+	}
+	if err := checkKeys(&testModel{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkKeys(new(testModel)); err == nil {
+		t.Fatal("expecting error of empty key")
+	}
+}
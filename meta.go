@@ -0,0 +1,45 @@
+package dbmod
+
+import (
+	"reflect"
+
+	"git.kanosolution.net/kano/kaos"
+)
+
+// modelMeta keeps information of a model that is resolved once from its type
+type modelMeta struct {
+	fields     map[string]reflect.StructField
+	policy     *FieldPolicy
+	softDelete *softDelete
+	version    *versioning
+	rules      []*fieldRule
+}
+
+func newModelMeta(model *kaos.ServiceModel) (*modelMeta, error) {
+	var e error
+	meta := new(modelMeta)
+	meta.fields = modelFields(model.ModelType)
+	meta.policy = newFieldPolicy(meta.fields, model.Model)
+	if meta.softDelete, e = newSoftDelete(model.Model, meta.fields); e != nil {
+		return nil, e
+	}
+	if meta.version, e = newVersioning(model.Model, meta.fields); e != nil {
+		return nil, e
+	}
+	if meta.rules, e = parseRules(meta.fields); e != nil {
+		return nil, e
+	}
+	return meta, nil
+}
+
+func (m *mod) getMeta(model *kaos.ServiceModel) (*modelMeta, error) {
+	if meta, ok := m.metas.Load(model.ModelType); ok {
+		return meta.(*modelMeta), nil
+	}
+	meta, e := newModelMeta(model)
+	if e != nil {
+		return nil, e
+	}
+	m.metas.Store(model.ModelType, meta)
+	return meta, nil
+}
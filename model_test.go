@@ -0,0 +1,66 @@
+package dbmod
+
+import (
+	"reflect"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+)
+
+type testModel struct {
+	orm.DataModelBase `bson:"-" json:"-"`
+	ID                string `bson:"_id" json:"_id"`
+	Name              string
+	Age               int
+	Score             float64
+	Active            bool
+	Joined            time.Time
+	Tags              []string
+	Secret            string
+}
+
+func (m *testModel) TableName() string {
+	return "testmodels"
+}
+
+func (m *testModel) GetID(_ dbflex.IConnection) ([]string, []interface{}) {
+	return []string{"_id"}, []interface{}{m.ID}
+}
+
+func (m *testModel) SetID(keys ...interface{}) {
+	if len(keys) > 0 {
+		m.ID, _ = keys[0].(string)
+	}
+}
+
+type testKeyModel struct {
+	orm.DataModelBase `bson:"-" json:"-"`
+	Year              int
+	No                int
+	Title             string
+}
+
+func (m *testKeyModel) TableName() string {
+	return "testkeymodels"
+}
+
+func (m *testKeyModel) GetID(_ dbflex.IConnection) ([]string, []interface{}) {
+	return []string{"Year", "No"}, []interface{}{m.Year, m.No}
+}
+
+func (m *testKeyModel) SetID(keys ...interface{}) {
+	if len(keys) > 1 {
+		m.Year, _ = keys[0].(int)
+		m.No, _ = keys[1].(int)
+	}
+}
+
+func testServiceModel() *kaos.ServiceModel {
+	return &kaos.ServiceModel{Model: new(testModel), ModelType: reflect.TypeOf(testModel{})}
+}
+
+func testFields() map[string]reflect.StructField {
+	return modelFields(reflect.TypeOf(testModel{}))
+}
@@ -8,3 +8,17 @@ func MwPreSelectFields(fields ...string) kaos.MWFunc {
 		return true, nil
 	}
 }
+
+func MwPreBulkMode(mode string) kaos.MWFunc {
+	return func(ctx *kaos.Context, payload interface{}) (bool, error) {
+		ctx.Data().Set(BulkModeTag, mode)
+		return true, nil
+	}
+}
+
+func MwPreDryRun() kaos.MWFunc {
+	return func(ctx *kaos.Context, payload interface{}) (bool, error) {
+		ctx.Data().Set(DryRunTag, true)
+		return true, nil
+	}
+}
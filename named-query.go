@@ -0,0 +1,141 @@
+package dbmod
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/sebarcode/codekit"
+)
+
+/*
+Keys of param of GetsBy and FindBy routes to page and sort the result of named query, they are not passed to the query.
+QuerySortParam is comma separated fields or a list, prefix - sorts descending
+*/
+const (
+	QuerySkipParam = "_skip"
+	QueryTakeParam = "_take"
+	QuerySortParam = "_sort"
+)
+
+// QueryScopeParam is key of param of named query holding scope of the request, it is read by ScopeQuery
+const QueryScopeParam = "mdb_query_scope"
+
+type queryScope struct {
+	parm    *dbflex.QueryParam
+	applied bool
+}
+
+func (s *queryScope) empty() bool {
+	return s.parm.Where == nil && len(s.parm.Sort) == 0 && s.parm.Skip == 0 && s.parm.Take == 0 && len(s.parm.Select) == 0
+}
+
+// check makes sure the named query applied the scope, so no data out of the scope is returned
+func (s *queryScope) check(queryName string) error {
+	if s.empty() || s.applied {
+		return nil
+	}
+	return newError(http.StatusNotImplemented, "query %s does not apply scope of the request, build its command with ScopeQuery", queryName)
+}
+
+/*
+ScopeQuery applies scope of the request to command of a named query, where is filter of the query itself.
+dbflex/orm gives no way to add filter to a named query from outside, so the query should call it on the command it builds:
+
+	cmd := dbmod.ScopeQuery(dbflex.From(m.TableName()).Select(), dbflex.Eq("Status", param.GetString("Status")), param)
+
+Scope has filters of the context (DbModFilter, tenant, soft delete, url query), DbModSelect, QueryParamTag and
+paging of the route. When the request is scoped, named query routes refuse result of query that does not call it
+*/
+func ScopeQuery(cmd dbflex.ICommand, where *dbflex.Filter, param codekit.M) dbflex.ICommand {
+	scope, _ := param.Get(QueryScopeParam, nil).(*queryScope)
+	if scope == nil {
+		if where != nil {
+			cmd.Where(where)
+		}
+		return cmd
+	}
+
+	scope.applied = true
+	parm := scope.parm
+	switch {
+	case where != nil && parm.Where != nil:
+		cmd.Where(dbflex.And(where, parm.Where))
+	case where != nil:
+		cmd.Where(where)
+	case parm.Where != nil:
+		cmd.Where(parm.Where)
+	}
+	if len(parm.Select) > 0 {
+		cmd.Select(parm.Select...)
+	}
+	if len(parm.Sort) > 0 {
+		cmd.OrderBy(parm.Sort...)
+	}
+	if parm.Skip > 0 {
+		cmd.Skip(parm.Skip)
+	}
+	if parm.Take > 0 {
+		cmd.Take(parm.Take)
+	}
+	return cmd
+}
+
+// namedQueryParam splits param of named query route into param of the query and scope of the request,
+// the scope is passed to the query as QueryScopeParam
+func namedQueryParam(ctx *kaos.Context, meta *modelMeta, param codekit.M) (codekit.M, *queryScope, error) {
+	queryParam := codekit.M{}
+	qp := dbflex.NewQueryParam()
+	for key, value := range param {
+		switch key {
+		case QueryScopeParam:
+		case QuerySkipParam:
+			qp.Skip = param.GetInt(key)
+		case QueryTakeParam:
+			qp.Take = param.GetInt(key)
+		case QuerySortParam:
+			switch sorts := value.(type) {
+			case string:
+				for _, sortField := range strings.Split(sorts, ",") {
+					if sortField = strings.TrimSpace(sortField); sortField != "" {
+						qp.Sort = append(qp.Sort, sortField)
+					}
+				}
+			case []string:
+				qp.Sort = append(qp.Sort, sorts...)
+			case []interface{}:
+				for _, sortField := range sorts {
+					qp.Sort = append(qp.Sort, fmt.Sprintf("%v", sortField))
+				}
+			}
+		default:
+			queryParam.Set(key, value)
+		}
+	}
+
+	parm, e := scopedQueryParam(ctx, meta, qp)
+	if e != nil {
+		return nil, nil, e
+	}
+	scope := &queryScope{parm: parm}
+	if !scope.empty() {
+		queryParam.Set(QueryScopeParam, scope)
+	}
+	return queryParam, scope, nil
+}
+
+// countScope returns param to count result of named query, it has where of the scope only
+func countScope(queryParam codekit.M, scope *queryScope) (codekit.M, *queryScope) {
+	countParam := codekit.M{}
+	for key, value := range queryParam {
+		countParam.Set(key, value)
+	}
+	counted := &queryScope{parm: dbflex.NewQueryParam().SetWhere(scope.parm.Where)}
+	countParam.Set(QueryScopeParam, counted)
+	if counted.empty() {
+		delete(countParam, QueryScopeParam)
+	}
+	return countParam, counted
+}
@@ -0,0 +1,79 @@
+package dbmod
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"git.kanosolution.net/kano/dbflex"
+	"github.com/sebarcode/codekit"
+)
+
+type testCommand struct {
+	dbflex.ICommand
+	where  *dbflex.Filter
+	fields []string
+	sorts  []string
+	skip   int
+	take   int
+}
+
+func (c *testCommand) Where(f *dbflex.Filter) dbflex.ICommand  { c.where = f; return c }
+func (c *testCommand) Select(fields ...string) dbflex.ICommand { c.fields = fields; return c }
+func (c *testCommand) OrderBy(sorts ...string) dbflex.ICommand { c.sorts = sorts; return c }
+func (c *testCommand) Skip(n int) dbflex.ICommand              { c.skip = n; return c }
+func (c *testCommand) Take(n int) dbflex.ICommand              { c.take = n; return c }
+
+func TestScopeQuery(t *testing.T) {
+	own := dbflex.Eq("Name", "x")
+	parm := dbflex.NewQueryParam().SetWhere(dbflex.Eq("Tenant", "t1")).SetSort("-Age").SetSkip(10).SetTake(5).SetSelect("Name")
+	scope := &queryScope{parm: parm}
+	param := codekit.M{"Name": "x", QueryScopeParam: scope}
+
+	if e := scope.check("ByName"); e == nil {
+		t.Fatal("expecting error before scope is applied")
+	} else if me, ok := e.(*Error); !ok || me.Code != http.StatusNotImplemented {
+		t.Fatalf("unexpected error %v", e)
+	}
+
+	cmd := new(testCommand)
+	ScopeQuery(cmd, own, param)
+	want := &testCommand{where: dbflex.And(own, parm.Where), fields: []string{"Name"}, sorts: []string{"-Age"}, skip: 10, take: 5}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("got %+v, want %+v", cmd, want)
+	}
+	if e := scope.check("ByName"); e != nil {
+		t.Fatal(e)
+	}
+
+	cmd = new(testCommand)
+	ScopeQuery(cmd, own, codekit.M{"Name": "x"})
+	if !reflect.DeepEqual(cmd, &testCommand{where: own}) {
+		t.Fatalf("unscoped query should keep its own filter, got %+v", cmd)
+	}
+
+	// client can not send scope in param
+	cmd = new(testCommand)
+	ScopeQuery(cmd, nil, codekit.M{QueryScopeParam: map[string]interface{}{"parm": nil}})
+	if !reflect.DeepEqual(cmd, new(testCommand)) {
+		t.Fatalf("got %+v", cmd)
+	}
+}
+
+func TestCountScope(t *testing.T) {
+	parm := dbflex.NewQueryParam().SetWhere(dbflex.Eq("Tenant", "t1")).SetSkip(10).SetTake(5)
+	queryParam := codekit.M{"Name": "x", QueryScopeParam: &queryScope{parm: parm}}
+
+	countParam, counted := countScope(queryParam, &queryScope{parm: parm})
+	if counted.parm.Where != parm.Where || counted.parm.Skip != 0 || counted.parm.Take != 0 {
+		t.Fatalf("count should keep where only, got %+v", counted.parm)
+	}
+	if countParam.Get(QueryScopeParam, nil) != counted || countParam.GetString("Name") != "x" {
+		t.Fatalf("unexpected count param %v", countParam)
+	}
+
+	countParam, counted = countScope(codekit.M{}, &queryScope{parm: dbflex.NewQueryParam().SetTake(5)})
+	if _, has := countParam[QueryScopeParam]; has || counted.check("ByName") != nil {
+		t.Fatal("count without where should not be scoped")
+	}
+}
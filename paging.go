@@ -0,0 +1,123 @@
+package dbmod
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex"
+)
+
+/*
+CursorParam is key of dbflex.QueryParam.Param to activate keyset pagination on gets route.
+Send it empty for the first page and the "next" value of previous response for the next one,
+Skip will be ignored and Take determines page size
+*/
+const CursorParam = "cursor"
+
+type pageCursor struct {
+	Sort   []string        `json:"s"`
+	Values json.RawMessage `json:"v"`
+}
+
+// keysetSort appends id fields to sort so every row has unique position
+func keysetSort(sorts []string, idFields []string) []string {
+	res := append([]string{}, sorts...)
+	for _, idField := range idFields {
+		found := false
+		for _, sort := range sorts {
+			if strings.EqualFold(strings.TrimLeft(sort, "-+"), idField) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, idField)
+		}
+	}
+	return res
+}
+
+func encodeCursor(fields map[string]reflect.StructField, sorts []string, row reflect.Value) (string, error) {
+	row = reflect.Indirect(row)
+	values := make([]interface{}, len(sorts))
+	for idx, sort := range sorts {
+		name, _ := lookupField(fields, strings.TrimLeft(sort, "-+"))
+		f, ok := fields[name]
+		if !ok {
+			return "", fmt.Errorf("field %s can not be used for cursor", name)
+		}
+		fv, err := row.FieldByIndexErr(f.Index)
+		if err != nil {
+			return "", err
+		}
+		values[idx] = fv.Interface()
+	}
+
+	bs, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	bs, err = json.Marshal(pageCursor{Sort: sorts, Values: bs})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// decodeCursor returns filter to select rows positioned after the cursor
+func decodeCursor(fields map[string]reflect.StructField, sorts []string, cursor string) (*dbflex.Filter, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errBadRequest("invalid cursor")
+	}
+	pc := pageCursor{}
+	if err = json.Unmarshal(bs, &pc); err != nil {
+		return nil, errBadRequest("invalid cursor")
+	}
+	if strings.Join(pc.Sort, ",") != strings.Join(sorts, ",") {
+		return nil, errBadRequest("cursor does not match sort")
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(pc.Values)))
+	dec.UseNumber()
+	raws := []interface{}{}
+	if err = dec.Decode(&raws); err != nil || len(raws) != len(sorts) {
+		return nil, errBadRequest("invalid cursor")
+	}
+
+	names := make([]string, len(sorts))
+	values := make([]interface{}, len(sorts))
+	for idx, sort := range sorts {
+		name, ft := lookupField(fields, strings.TrimLeft(sort, "-+"))
+		names[idx] = name
+		switch raw := raws[idx].(type) {
+		case nil:
+			values[idx] = nil
+		case json.Number:
+			values[idx], err = parseFieldValue(ft, raw.String())
+		default:
+			values[idx], err = parseFieldValue(ft, fmt.Sprintf("%v", raw))
+		}
+		if err != nil {
+			return nil, errBadRequest("invalid cursor")
+		}
+	}
+
+	ors := make([]*dbflex.Filter, len(sorts))
+	for idx, sort := range sorts {
+		ands := []*dbflex.Filter{}
+		for prev := 0; prev < idx; prev++ {
+			ands = append(ands, dbflex.Eq(names[prev], values[prev]))
+		}
+		if strings.HasPrefix(sort, "-") {
+			ands = append(ands, dbflex.Lt(names[idx], values[idx]))
+		} else {
+			ands = append(ands, dbflex.Gt(names[idx], values[idx]))
+		}
+		ors[idx] = joinFilters(dbflex.And, ands)
+	}
+	return joinFilters(dbflex.Or, ors), nil
+}
@@ -0,0 +1,101 @@
+package dbmod
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+)
+
+func TestKeysetSort(t *testing.T) {
+	cases := []struct {
+		sorts    []string
+		idFields []string
+		want     []string
+	}{
+		{nil, []string{"_id"}, []string{"_id"}},
+		{[]string{"-Age"}, []string{"_id"}, []string{"-Age", "_id"}},
+		{[]string{"-_id"}, []string{"_id"}, []string{"-_id"}},
+		{[]string{"No"}, []string{"Year", "No"}, []string{"No", "Year"}},
+	}
+	for _, c := range cases {
+		if got := keysetSort(c.sorts, c.idFields); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("keysetSort(%v, %v) = %v, want %v", c.sorts, c.idFields, got, c.want)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	joined := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	row := &testModel{ID: "a", Name: "x", Age: 3, Score: 1.5, Joined: joined}
+	cases := []struct {
+		name  string
+		sorts []string
+		want  *dbflex.Filter
+	}{
+		{name: "id", sorts: []string{"_id"}, want: dbflex.Gt("_id", "a")},
+		{name: "descending", sorts: []string{"-Age", "_id"},
+			want: dbflex.Or(dbflex.Lt("Age", 3), dbflex.And(dbflex.Eq("Age", 3), dbflex.Gt("_id", "a")))},
+		{name: "float and time", sorts: []string{"Score", "-Joined", "_id"},
+			want: dbflex.Or(
+				dbflex.Gt("Score", 1.5),
+				dbflex.And(dbflex.Eq("Score", 1.5), dbflex.Lt("Joined", joined)),
+				dbflex.And(dbflex.Eq("Score", 1.5), dbflex.Eq("Joined", joined), dbflex.Gt("_id", "a")))},
+		{name: "case insensitive field", sorts: []string{"age", "_id"},
+			want: dbflex.Or(dbflex.Gt("Age", 3), dbflex.And(dbflex.Eq("Age", 3), dbflex.Gt("_id", "a")))},
+	}
+
+	fields := testFields()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cursor, err := encodeCursor(fields, c.sorts, reflect.ValueOf(row))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeCursor(fields, c.sorts, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorError(t *testing.T) {
+	fields := testFields()
+	sorts := []string{"Age", "_id"}
+	makeCursor := func(sorts []string, values string) string {
+		bs, err := json.Marshal(pageCursor{Sort: sorts, Values: json.RawMessage(values)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(bs)
+	}
+
+	cases := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!"},
+		{name: "not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("x"))},
+		{name: "other sort", cursor: makeCursor([]string{"-Age", "_id"}, `[3,"a"]`)},
+		{name: "missing value", cursor: makeCursor(sorts, `[3]`)},
+		{name: "invalid value", cursor: makeCursor(sorts, `["x","a"]`)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if f, err := decodeCursor(fields, sorts, c.cursor); err == nil {
+				t.Fatalf("expecting error, got %#v", f)
+			}
+		})
+	}
+
+	if _, err := encodeCursor(fields, []string{"Unknown"}, reflect.ValueOf(&testModel{})); err == nil {
+		t.Fatal("expecting error of unknown sort field")
+	}
+}
@@ -0,0 +1,128 @@
+package dbmod
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+// patchData applies merge patch of the request to current data and updates only the touched fields
+func (m *mod) patchData(ctx *kaos.Context, tx *datahub.Hub, model *kaos.ServiceModel, payload *PatchRequest) (orm.DataModel, error) {
+	if payload == nil || len(payload.Patch) == 0 {
+		return nil, errBadRequest("patch is empty")
+	}
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return nil, e
+	}
+
+	current := getDataModel(model)
+	keyValues, e := setKeys(current, payload.Keys)
+	if e != nil {
+		return nil, e
+	}
+	where := joinFilters(dbflex.And, append([]*dbflex.Filter{keysFilter(current, keyValues)}, filtersFromCtx(ctx, meta.softDelete)...))
+	count, e := aggregateCount(tx, current, where)
+	if e != nil {
+		return nil, e
+	}
+	if count == 0 {
+		return nil, errNotFound("data not found")
+	}
+	if e = tx.GetByFilter(current, where); e != nil {
+		return nil, e
+	}
+
+	idFields, _ := current.GetID(nil)
+	patch := map[string]interface{}{}
+	touched := []string{}
+	for key, value := range payload.Patch {
+		name, ft := lookupField(meta.fields, key)
+		if ft == nil {
+			return nil, errBadRequest("unknown field %s", key)
+		}
+		if hasFieldName(idFields, name) {
+			return nil, errBadRequest("key field %s can not be patched", name)
+		}
+		patch[name] = value
+		touched = append(touched, name)
+	}
+	sort.Strings(touched)
+
+	bs, e := json.Marshal(current)
+	if e != nil {
+		return nil, e
+	}
+	target := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	if e = dec.Decode(&target); e != nil {
+		return nil, e
+	}
+
+	dm, e := decodePatched(model, mergePatch(target, patch))
+	if e != nil {
+		return nil, e
+	}
+	if meta.version != nil {
+		meta.version.ifMatch(ctx, dm)
+	}
+	return dm, m.writeData(ctx, tx, model, dm, writePatch, touched...)
+}
+
+// mergePatch applies patch to target as described by RFC 7396, null removes the field
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = map[string]interface{}{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if pv, ok := asMap(value); ok {
+			tv, _ := asMap(target[key])
+			target[key] = mergePatch(tv, pv)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
+
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case codekit.M:
+		return v, true
+	}
+	return nil, false
+}
+
+// decodePatched converts patched data into the model, value which does not fit type of its field is rejected
+func decodePatched(model *kaos.ServiceModel, data map[string]interface{}) (orm.DataModel, error) {
+	bs, e := json.Marshal(data)
+	if e != nil {
+		return nil, errBadRequest("invalid patch: %s", e.Error())
+	}
+
+	dm := getDataModel(model)
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.DisallowUnknownFields()
+	if e = dec.Decode(dm); e != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(e, &te) {
+			return nil, errBadRequest("invalid value of %s, expecting %s", te.Field, te.Type.String())
+		}
+		return nil, errBadRequest("invalid patch: %s", e.Error())
+	}
+	return dm, nil
+}
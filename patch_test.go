@@ -0,0 +1,79 @@
+package dbmod
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// cases are taken from appendix A of RFC 7396
+	cases := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`null`, `{"a":"b"}`, `{"a":"b"}`},
+	}
+
+	decode := func(s string) map[string]interface{} {
+		var res map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	for _, c := range cases {
+		got := mergePatch(decode(c.target), decode(c.patch))
+		if want := decode(c.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("patch %s on %s: got %v, want %v", c.patch, c.target, got, want)
+		}
+	}
+}
+
+func TestDecodePatched(t *testing.T) {
+	cases := []struct {
+		name string
+		data map[string]interface{}
+		want *testModel
+		err  bool
+	}{
+		{name: "valid", data: map[string]interface{}{"_id": "a", "Name": "x", "Age": json.Number("3"), "Tags": []interface{}{"t"}},
+			want: &testModel{ID: "a", Name: "x", Age: 3, Tags: []string{"t"}}},
+		{name: "unknown field", data: map[string]interface{}{"Unknown": 1}, err: true},
+		{name: "wrong type", data: map[string]interface{}{"Age": "x"}, err: true},
+		{name: "fraction on int", data: map[string]interface{}{"Age": json.Number("1.5")}, err: true},
+		{name: "wrong element type", data: map[string]interface{}{"Tags": []interface{}{1}}, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := decodePatched(testServiceModel(), c.data)
+			if c.err {
+				var me *Error
+				if !errors.As(err, &me) || me.Code != http.StatusBadRequest {
+					t.Fatalf("expecting bad request, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package dbmod
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"github.com/sebarcode/codekit"
+)
+
+const (
+	queryOpSeparator   = "__"
+	queryNegatePrefix  = "-"
+	queryListSeparator = ","
+)
+
+var (
+	queryOps = []string{"eq", "ne", "gt", "gte", "lt", "lte", "in", "nin", "contains", "startwith", "endwith", "range"}
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// modelFields returns exported fields of a model struct keyed by their db name,
+// fields of embedded struct are promoted
+func modelFields(rt reflect.Type) map[string]reflect.StructField {
+	res := map[string]reflect.StructField{}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return res
+	}
+
+	embeddeds := []reflect.StructField{}
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && ft != timeType {
+			embeddeds = append(embeddeds, f)
+			continue
+		}
+
+		name := fieldDbName(f)
+		if name == "-" {
+			continue
+		}
+		res[name] = f
+	}
+
+	for _, emb := range embeddeds {
+		for name, f := range modelFields(emb.Type) {
+			if _, has := res[name]; has {
+				continue
+			}
+			f.Index = append(append([]int{}, emb.Index...), f.Index...)
+			res[name] = f
+		}
+	}
+	return res
+}
+
+func fieldDbName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get(codekit.TagName()), ",")[0]
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+func lookupField(fields map[string]reflect.StructField, name string) (string, reflect.Type) {
+	if f, ok := fields[name]; ok {
+		return name, f.Type
+	}
+	for fieldName, f := range fields {
+		if strings.EqualFold(fieldName, name) {
+			return fieldName, f.Type
+		}
+	}
+	return name, nil
+}
+
+// parseFieldValue converts string value into the type of model field,
+// when type is nil (unknown field) the string is returned as is
+func parseFieldValue(ft reflect.Type, s string) (interface{}, error) {
+	if ft == nil {
+		return s, nil
+	}
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+
+	if ft == timeType {
+		if dt, err := time.Parse(time.RFC3339, s); err == nil {
+			return dt, nil
+		}
+		return time.Parse("2006-01-02", s)
+	}
+
+	switch ft.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+
+	case reflect.Int:
+		n, err := strconv.ParseInt(s, 10, 0)
+		return int(n), err
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(s, 10, ft.Bits())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(s, 10, ft.Bits())
+
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, ft.Bits())
+	}
+
+	return s, nil
+}
+
+/*
+parseQueryFilter compiles url query into dbflex filters, fields not allowed by policy are rejected. Key is written as field__op, op is one of
+eq (default), ne, gt, gte, lt, lte, in, nin, contains, startwith, endwith and range. Values of in, nin and range
+are comma separated. Prefix - negates eq, ne, in, nin, gt, gte, lt and lte. A bool field without value means true,
+ie: ?age__gte=18&status__in=open,draft&name__contains=x&-deleted
+*/
+func parseQueryFilter(fields map[string]reflect.StructField, policy *FieldPolicy, values url.Values) ([]*dbflex.Filter, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	filters := []*dbflex.Filter{}
+	for _, key := range keys {
+		vs := values[key]
+		if len(vs) == 0 {
+			continue
+		}
+
+		name := key
+		negate := strings.HasPrefix(name, queryNegatePrefix)
+		if negate {
+			name = name[len(queryNegatePrefix):]
+		}
+		op := "eq"
+		if idx := strings.LastIndex(name, queryOpSeparator); idx > 0 {
+			if suffix := strings.ToLower(name[idx+len(queryOpSeparator):]); codekit.HasMember(queryOps, suffix) {
+				op = suffix
+				name = name[:idx]
+			}
+		}
+
+		fieldName, ft := lookupField(fields, name)
+		if !policy.CanFilter(fieldName) {
+			return nil, errBadRequest("field %s is not filterable", fieldName)
+		}
+		f, err := buildQueryFilter(fieldName, ft, op, negate, vs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query %s: %s", key, err.Error())
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+func buildQueryFilter(field string, ft reflect.Type, op string, negate bool, vs []string) (*dbflex.Filter, error) {
+	if negate {
+		switch op {
+		case "eq":
+			op = "ne"
+		case "ne":
+			op = "eq"
+		case "in":
+			op = "nin"
+		case "nin":
+			op = "in"
+		case "gt":
+			op = "lte"
+		case "gte":
+			op = "lt"
+		case "lt":
+			op = "gte"
+		case "lte":
+			op = "gt"
+		default:
+			return nil, fmt.Errorf("operator %s can not be negated", op)
+		}
+	}
+
+	if isBoolType(ft) && len(vs) == 1 && vs[0] == "" {
+		vs = []string{"true"}
+	}
+
+	switch op {
+	case "in", "nin", "range":
+		items := []string{}
+		for _, v := range vs {
+			items = append(items, strings.Split(v, queryListSeparator)...)
+		}
+		values, err := parseFieldValues(ft, items)
+		if err != nil {
+			return nil, err
+		}
+		if op == "in" {
+			return dbflex.In(field, values...), nil
+		} else if op == "nin" {
+			return dbflex.Nin(field, values...), nil
+		}
+		if len(values) != 2 {
+			return nil, fmt.Errorf("range needs 2 values")
+		}
+		return dbflex.Range(field, values[0], values[1]), nil
+
+	case "contains":
+		return dbflex.Contains(field, vs...), nil
+
+	case "startwith", "endwith":
+		filters := make([]*dbflex.Filter, len(vs))
+		for idx, v := range vs {
+			if op == "startwith" {
+				filters[idx] = dbflex.StartWith(field, v)
+			} else {
+				filters[idx] = dbflex.EndWith(field, v)
+			}
+		}
+		return joinFilters(dbflex.Or, filters), nil
+	}
+
+	values, err := parseFieldValues(ft, vs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "eq":
+		if len(values) > 1 {
+			return dbflex.In(field, values...), nil
+		}
+		return dbflex.Eq(field, values[0]), nil
+
+	case "ne":
+		if len(values) > 1 {
+			return dbflex.Nin(field, values...), nil
+		}
+		return dbflex.Ne(field, values[0]), nil
+	}
+
+	filters := make([]*dbflex.Filter, len(values))
+	for idx, v := range values {
+		switch op {
+		case "gt":
+			filters[idx] = dbflex.Gt(field, v)
+		case "gte":
+			filters[idx] = dbflex.Gte(field, v)
+		case "lt":
+			filters[idx] = dbflex.Lt(field, v)
+		case "lte":
+			filters[idx] = dbflex.Lte(field, v)
+		}
+	}
+	return joinFilters(dbflex.And, filters), nil
+}
+
+func parseFieldValues(ft reflect.Type, vs []string) ([]interface{}, error) {
+	values := make([]interface{}, len(vs))
+	for idx, v := range vs {
+		value, err := parseFieldValue(ft, v)
+		if err != nil {
+			return nil, err
+		}
+		values[idx] = value
+	}
+	return values, nil
+}
+
+func joinFilters(fn func(...*dbflex.Filter) *dbflex.Filter, filters []*dbflex.Filter) *dbflex.Filter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return fn(filters...)
+}
+
+func isBoolType(ft reflect.Type) bool {
+	if ft == nil {
+		return false
+	}
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	return ft.Kind() == reflect.Bool
+}
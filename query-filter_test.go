@@ -0,0 +1,116 @@
+package dbmod
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+)
+
+func TestParseQueryFilter(t *testing.T) {
+	joined := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		query  string
+		policy *FieldPolicy
+		want   []*dbflex.Filter
+		err    bool
+	}{
+		{name: "eq", query: "Age=18", want: []*dbflex.Filter{dbflex.Eq("Age", 18)}},
+		{name: "case insensitive field", query: "age=18", want: []*dbflex.Filter{dbflex.Eq("Age", 18)}},
+		{name: "repeated eq", query: "Name=a&Name=b", want: []*dbflex.Filter{dbflex.In("Name", "a", "b")}},
+		{name: "gte", query: "Age__gte=18", want: []*dbflex.Filter{dbflex.Gte("Age", 18)}},
+		{name: "float", query: "Score__lt=7.5", want: []*dbflex.Filter{dbflex.Lt("Score", 7.5)}},
+		{name: "time", query: "Joined__gte=2024-01-02", want: []*dbflex.Filter{dbflex.Gte("Joined", joined)}},
+		{name: "in", query: "Age__in=1,2,3", want: []*dbflex.Filter{dbflex.In("Age", 1, 2, 3)}},
+		{name: "nin", query: "Name__nin=a,b", want: []*dbflex.Filter{dbflex.Nin("Name", "a", "b")}},
+		{name: "range", query: "Age__range=1,5", want: []*dbflex.Filter{dbflex.Range("Age", 1, 5)}},
+		{name: "contains", query: "Name__contains=x", want: []*dbflex.Filter{dbflex.Contains("Name", "x")}},
+		{name: "startwith", query: "Name__startwith=a&Name__startwith=b",
+			want: []*dbflex.Filter{dbflex.Or(dbflex.StartWith("Name", "a"), dbflex.StartWith("Name", "b"))}},
+		{name: "negate eq", query: "-Name=x", want: []*dbflex.Filter{dbflex.Ne("Name", "x")}},
+		{name: "negate in", query: "-Name__in=a,b", want: []*dbflex.Filter{dbflex.Nin("Name", "a", "b")}},
+		{name: "negate gte", query: "-Age__gte=18", want: []*dbflex.Filter{dbflex.Lt("Age", 18)}},
+		{name: "bool shorthand", query: "Active", want: []*dbflex.Filter{dbflex.Eq("Active", true)}},
+		{name: "negate bool shorthand", query: "-Active", want: []*dbflex.Filter{dbflex.Ne("Active", true)}},
+		{name: "bool value", query: "Active=false", want: []*dbflex.Filter{dbflex.Eq("Active", false)}},
+		{name: "sorted by key", query: "Name=x&Age=1", want: []*dbflex.Filter{dbflex.Eq("Age", 1), dbflex.Eq("Name", "x")}},
+		{name: "invalid int", query: "Age=abc", err: true},
+		{name: "invalid bool", query: "Active=maybe", err: true},
+		{name: "invalid time", query: "Joined=yesterday", err: true},
+		{name: "invalid in", query: "Age__in=1,x", err: true},
+		{name: "range needs 2 values", query: "Age__range=1", err: true},
+		{name: "negate contains", query: "-Name__contains=x", err: true},
+		{name: "not filterable", query: "Secret=x", policy: &FieldPolicy{NonFilterable: []string{"Secret"}}, err: true},
+	}
+
+	fields := testFields()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			values, err := url.ParseQuery(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseQueryFilter(fields, c.policy, values)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expecting error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBuildQueryFilter(t *testing.T) {
+	intType := reflect.TypeOf(int(0))
+	uintType := reflect.TypeOf(uint8(0))
+	cases := []struct {
+		name   string
+		ft     reflect.Type
+		op     string
+		negate bool
+		values []string
+		want   *dbflex.Filter
+		err    bool
+	}{
+		{name: "unknown field keeps string", op: "eq", values: []string{"1"}, want: dbflex.Eq("F", "1")},
+		{name: "ne of many", ft: intType, op: "ne", values: []string{"1", "2"}, want: dbflex.Nin("F", 1, 2)},
+		{name: "negate ne", ft: intType, op: "ne", negate: true, values: []string{"1"}, want: dbflex.Eq("F", 1)},
+		{name: "negate nin", ft: intType, op: "nin", negate: true, values: []string{"1,2"}, want: dbflex.In("F", 1, 2)},
+		{name: "negate lt", ft: intType, op: "lt", negate: true, values: []string{"1"}, want: dbflex.Gte("F", 1)},
+		{name: "lte of many", ft: intType, op: "lte", values: []string{"1", "2"},
+			want: dbflex.And(dbflex.Lte("F", 1), dbflex.Lte("F", 2))},
+		{name: "uint", ft: uintType, op: "gt", values: []string{"7"}, want: dbflex.Gt("F", uint64(7))},
+		{name: "uint overflow", ft: uintType, op: "gt", values: []string{"300"}, err: true},
+		{name: "negative uint", ft: uintType, op: "eq", values: []string{"-1"}, err: true},
+		{name: "negate range", ft: intType, op: "range", negate: true, values: []string{"1,2"}, err: true},
+		{name: "negate endwith", op: "endwith", negate: true, values: []string{"x"}, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := buildQueryFilter("F", c.ft, c.op, c.negate, c.values)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expecting error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package dbmod
+
+import (
+	"reflect"
+
+	"git.kanosolution.net/kano/kaos"
+)
+
+/*
+wrapFn returns route function which calls before prior to fn. When before fails fn is skipped
+and the error is returned, otherwise after returned by before, if any, is called once fn is done
+*/
+func wrapFn(fn reflect.Value, before func(ctx *kaos.Context, payload interface{}) (func(), error)) reflect.Value {
+	ft := fn.Type()
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		ctx, _ := args[0].Interface().(*kaos.Context)
+		var payload interface{}
+		if len(args) > 1 {
+			payload = args[1].Interface()
+		}
+
+		after, e := before(ctx, payload)
+		if e != nil {
+			outs := make([]reflect.Value, ft.NumOut())
+			for idx := range outs {
+				outs[idx] = reflect.Zero(ft.Out(idx))
+			}
+			outs[len(outs)-1] = reflect.ValueOf(&e).Elem()
+			return outs
+		}
+		if after != nil {
+			defer after()
+		}
+		return fn.Call(args)
+	})
+}
+
+// RouteMWModel can be implemented by orm.DataModel to attach middlewares to its generated routes,
+// key is route name such as save, delete or GetsByStatus
+type RouteMWModel interface {
+	RouteMW() map[string][]kaos.MWFunc
+}
+
+// AddRouteMW attaches middlewares to generated routes of given name of every model, ie save, delete or GetsByStatus.
+// They run in order after tenant is resolved, a middleware returning false rejects the request with forbidden error
+func (m *mod) AddRouteMW(routeName string, fns ...kaos.MWFunc) {
+	if m.routeMWs == nil {
+		m.routeMWs = map[string][]kaos.MWFunc{}
+	}
+	m.routeMWs[routeName] = append(m.routeMWs[routeName], fns...)
+}
+
+// routeMWFn wraps route function with middlewares of the route, the ones of the module run before the ones of the model
+func (m *mod) routeMWFn(model *kaos.ServiceModel, routeName string, fn reflect.Value) reflect.Value {
+	fns := append([]kaos.MWFunc{}, m.routeMWs[routeName]...)
+	if rm, ok := model.Model.(RouteMWModel); ok {
+		fns = append(fns, rm.RouteMW()[routeName]...)
+	}
+	if len(fns) == 0 {
+		return fn
+	}
+
+	return wrapFn(fn, func(ctx *kaos.Context, payload interface{}) (func(), error) {
+		for _, mw := range fns {
+			ok, e := mw(ctx, payload)
+			if e != nil {
+				return nil, e
+			}
+			if !ok {
+				return nil, errForbidden("%s is not allowed", routeName)
+			}
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,96 @@
+package dbmod
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.kanosolution.net/kano/kaos"
+)
+
+type testRouteMWModel struct {
+	testModel
+	mws map[string][]kaos.MWFunc
+}
+
+func (m *testRouteMWModel) RouteMW() map[string][]kaos.MWFunc {
+	return m.mws
+}
+
+func testRouteFn(calls *[]string) reflect.Value {
+	return reflect.ValueOf(func(ctx *kaos.Context, name string) (string, error) {
+		*calls = append(*calls, "fn")
+		return "hello " + name, nil
+	})
+}
+
+func callTestRoute(fn reflect.Value, name string) (string, error) {
+	outs := fn.Call([]reflect.Value{reflect.Zero(reflect.TypeOf((*kaos.Context)(nil))), reflect.ValueOf(name)})
+	e, _ := outs[1].Interface().(error)
+	return outs[0].String(), e
+}
+
+func TestWrapFn(t *testing.T) {
+	calls := []string{}
+	fn := wrapFn(testRouteFn(&calls), func(ctx *kaos.Context, payload interface{}) (func(), error) {
+		calls = append(calls, "before "+payload.(string))
+		return func() { calls = append(calls, "after") }, nil
+	})
+	res, err := callTestRoute(fn, "x")
+	if err != nil || res != "hello x" {
+		t.Fatalf("got %q %v", res, err)
+	}
+	if !reflect.DeepEqual(calls, []string{"before x", "fn", "after"}) {
+		t.Fatalf("got calls %v", calls)
+	}
+
+	calls = []string{}
+	fn = wrapFn(testRouteFn(&calls), func(ctx *kaos.Context, payload interface{}) (func(), error) {
+		return nil, errors.New("rejected")
+	})
+	res, err = callTestRoute(fn, "x")
+	if err == nil || err.Error() != "rejected" || res != "" {
+		t.Fatalf("got %q %v", res, err)
+	}
+	if len(calls) > 0 {
+		t.Fatalf("fn should not be called, got calls %v", calls)
+	}
+}
+
+func TestRouteMWFn(t *testing.T) {
+	calls := []string{}
+	mw := func(name string, ok bool) kaos.MWFunc {
+		return func(ctx *kaos.Context, payload interface{}) (bool, error) {
+			calls = append(calls, name)
+			return ok, nil
+		}
+	}
+
+	m := new(mod)
+	model := &kaos.ServiceModel{Model: &testRouteMWModel{mws: map[string][]kaos.MWFunc{"save": {mw("model", true)}}}}
+	fn := testRouteFn(&calls)
+	if got := m.routeMWFn(model, "delete", fn); got.Pointer() != fn.Pointer() {
+		t.Fatal("route without middleware should not be wrapped")
+	}
+
+	m.AddRouteMW("save", mw("module", true))
+	if _, err := callTestRoute(m.routeMWFn(model, "save", fn), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"module", "model", "fn"}) {
+		t.Fatalf("got calls %v", calls)
+	}
+
+	calls = []string{}
+	m.AddRouteMW("delete", mw("deny", false))
+	_, err := callTestRoute(m.routeMWFn(model, "delete", fn), "x")
+	var de *Error
+	if !errors.As(err, &de) || de.Code != http.StatusForbidden || !strings.Contains(de.Message, "delete") {
+		t.Fatalf("expecting forbidden error, got %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"deny"}) {
+		t.Fatalf("got calls %v", calls)
+	}
+}
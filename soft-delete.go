@@ -0,0 +1,105 @@
+package dbmod
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+// SoftDeleteModel can be implemented by orm.DataModel to mark data as deleted instead of removing it.
+// flagField is a bool field, timeField is an optional time.Time or *time.Time field to keep deletion time.
+// Data marked as deleted is excluded from get, gets, find and query routes, and can be restored or purged
+type SoftDeleteModel interface {
+	SoftDeleteFields() (flagField, timeField string)
+}
+
+type softDelete struct {
+	flagField string
+	flagIndex []int
+	timeField string
+	timeIndex []int
+	timeType  reflect.Type
+}
+
+func newSoftDelete(mdl interface{}, fields map[string]reflect.StructField) (*softDelete, error) {
+	sdm, ok := mdl.(SoftDeleteModel)
+	if !ok {
+		return nil, nil
+	}
+
+	sd := new(softDelete)
+	flagField, timeField := sdm.SoftDeleteFields()
+	f, ok := fields[flagField]
+	if !ok || f.Type.Kind() != reflect.Bool {
+		return nil, fmt.Errorf("soft delete: %s should be a bool field", flagField)
+	}
+	sd.flagField = flagField
+	sd.flagIndex = f.Index
+
+	if timeField != "" {
+		f, ok = fields[timeField]
+		if !ok || (f.Type != timeType && f.Type != reflect.PointerTo(timeType)) {
+			return nil, fmt.Errorf("soft delete: %s should be a time field", timeField)
+		}
+		sd.timeField = timeField
+		sd.timeIndex = f.Index
+		sd.timeType = f.Type
+	}
+	return sd, nil
+}
+
+func (sd *softDelete) activeFilter() *dbflex.Filter {
+	return dbflex.Ne(sd.flagField, true)
+}
+
+func (sd *softDelete) deletedFilter() *dbflex.Filter {
+	return dbflex.Eq(sd.flagField, true)
+}
+
+// mark returns values and fields to be updated to mark data as deleted or restored
+func (sd *softDelete) mark(deleted bool) (codekit.M, []string) {
+	values := codekit.M{}.Set(sd.flagField, deleted)
+	fields := []string{sd.flagField}
+	if sd.timeField != "" {
+		if deleted {
+			values.Set(sd.timeField, time.Now())
+		} else if sd.timeType.Kind() == reflect.Ptr {
+			values.Set(sd.timeField, nil)
+		} else {
+			values.Set(sd.timeField, time.Time{})
+		}
+		fields = append(fields, sd.timeField)
+	}
+	return values, fields
+}
+
+// guard keeps data marked as deleted out of save, update and patch, it can only be restored.
+// Flag and time of the written data are reset, so writing data neither deletes nor restores it
+func (sd *softDelete) guard(h *datahub.Hub, dm orm.DataModel, op string) error {
+	rv := reflect.Indirect(reflect.ValueOf(dm))
+	if fv, err := rv.FieldByIndexErr(sd.flagIndex); err == nil {
+		fv.SetBool(false)
+	}
+	if sd.timeField != "" {
+		if fv, err := rv.FieldByIndexErr(sd.timeIndex); err == nil {
+			fv.Set(reflect.Zero(sd.timeType))
+		}
+	}
+	if op == writeInsert {
+		return nil
+	}
+
+	count, e := aggregateCount(h, dm, dbflex.And(idFilter(dm), sd.deletedFilter()))
+	if e != nil {
+		return e
+	}
+	if count > 0 {
+		return errNotFound("data not found")
+	}
+	return nil
+}
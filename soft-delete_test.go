@@ -0,0 +1,73 @@
+package dbmod
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testSoftModel struct {
+	testModel
+	Deleted   bool
+	DeletedAt *time.Time
+}
+
+func (m *testSoftModel) SoftDeleteFields() (string, string) {
+	return "Deleted", "DeletedAt"
+}
+
+func TestSoftDeleteMark(t *testing.T) {
+	sd, err := newSoftDelete(new(testSoftModel), modelFields(reflect.TypeOf(testSoftModel{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, fields := sd.mark(true)
+	if !reflect.DeepEqual(fields, []string{"Deleted", "DeletedAt"}) {
+		t.Fatalf("got fields %v", fields)
+	}
+	if values.Get("Deleted") != true {
+		t.Fatalf("expecting deleted flag, got %v", values.Get("Deleted"))
+	}
+	if dt, ok := values.Get("DeletedAt").(time.Time); !ok || dt.IsZero() {
+		t.Fatalf("expecting deletion time, got %v", values.Get("DeletedAt"))
+	}
+
+	values, _ = sd.mark(false)
+	if dt, has := values["DeletedAt"]; values.Get("Deleted") != false || !has || dt != nil {
+		t.Fatalf("expecting restored values, got %v", values)
+	}
+}
+
+func TestSoftDeleteGuard(t *testing.T) {
+	sd, err := newSoftDelete(new(testSoftModel), modelFields(reflect.TypeOf(testSoftModel{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	dm := &testSoftModel{Deleted: true, DeletedAt: &now}
+	if err = sd.guard(nil, dm, writeInsert); err != nil {
+		t.Fatal(err)
+	}
+	if dm.Deleted || dm.DeletedAt != nil {
+		t.Fatalf("flag and time are not reset: %v %v", dm.Deleted, dm.DeletedAt)
+	}
+}
+
+func TestNewSoftDelete(t *testing.T) {
+	if sd, err := newSoftDelete(new(testModel), nil); sd != nil || err != nil {
+		t.Fatalf("expecting no soft delete, got %v %v", sd, err)
+	}
+	if _, err := newSoftDelete(new(testBadSoftModel), modelFields(reflect.TypeOf(testBadSoftModel{}))); err == nil {
+		t.Fatal("expecting error of non bool flag")
+	}
+}
+
+type testBadSoftModel struct {
+	testModel
+}
+
+func (m *testBadSoftModel) SoftDeleteFields() (string, string) {
+	return "Name", ""
+}
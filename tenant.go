@@ -0,0 +1,128 @@
+package dbmod
+
+import (
+	"fmt"
+	"reflect"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+)
+
+// tenantTag is context key of tenant scope of running route, it is always set by the module so request can not override it
+const tenantTag = "mdb_tenant"
+
+// TenantResolver returns tenant of the request, an error or empty tenant rejects the request
+type TenantResolver func(ctx *kaos.Context) (interface{}, error)
+
+type tenancy struct {
+	field   string
+	resolve TenantResolver
+}
+
+type tenantScope struct {
+	field string
+	index []int
+	value interface{}
+}
+
+/*
+SetTenant scopes every generated route of models having the field to the tenant resolved from context:
+data of other tenants is invisible and the field is stamped with current tenant on every write.
+Models without the field are not scoped
+*/
+func (m *mod) SetTenant(field string, resolver TenantResolver) {
+	if field == "" || resolver == nil {
+		m.tenancy = nil
+		return
+	}
+	m.tenancy = &tenancy{field: field, resolve: resolver}
+}
+
+// Tenant returns tenant of running route, nil when the route is not scoped
+func Tenant(ctx *kaos.Context) interface{} {
+	if scope := tenantOf(ctx); scope != nil {
+		return scope.value
+	}
+	return nil
+}
+
+func tenantOf(ctx *kaos.Context) *tenantScope {
+	scope, _ := ctx.Data().Get(tenantTag, nil).(*tenantScope)
+	return scope
+}
+
+// tenantFn wraps route function to resolve tenant before it runs, and restores scope of outer route after
+func (m *mod) tenantFn(meta *modelMeta, fn reflect.Value) reflect.Value {
+	t := m.tenancy
+	if t == nil {
+		return fn
+	}
+	name, ft := lookupField(meta.fields, t.field)
+	return wrapFn(fn, func(ctx *kaos.Context, payload interface{}) (func(), error) {
+		prev := ctx.Data().Get(tenantTag, nil)
+		restore := func() {
+			ctx.Data().Set(tenantTag, prev)
+		}
+
+		var scope *tenantScope
+		if ft != nil {
+			value, e := t.resolve(ctx)
+			if e != nil {
+				return nil, errForbidden("tenant: %s", e.Error())
+			}
+			if value == nil || reflect.ValueOf(value).IsZero() {
+				return nil, errForbidden("tenant is required")
+			}
+			scope = &tenantScope{field: name, index: meta.fields[name].Index, value: value}
+		}
+		ctx.Data().Set(tenantTag, scope)
+		return restore, nil
+	})
+}
+
+func (s *tenantScope) filter() *dbflex.Filter {
+	return dbflex.Eq(s.field, s.value)
+}
+
+// stamp sets tenant field of data to current tenant
+func (s *tenantScope) stamp(data interface{}) error {
+	fv, err := reflect.Indirect(reflect.ValueOf(data)).FieldByIndexErr(s.index)
+	if err != nil {
+		return err
+	}
+	tv := reflect.ValueOf(s.value)
+	switch {
+	case tv.Type().AssignableTo(fv.Type()):
+		fv.Set(tv)
+	case tv.Kind() == fv.Kind() && tv.Type().ConvertibleTo(fv.Type()):
+		// named type of the same kind, conversion keeps the value as it is
+		fv.Set(tv.Convert(fv.Type()))
+	default:
+		return fmt.Errorf("tenant: %s can not be set to %s", tv.Type().String(), s.field)
+	}
+	return nil
+}
+
+// guardTenant stamps data with current tenant, data of other tenant with the same id can not be overwritten
+func guardTenant(ctx *kaos.Context, tx *datahub.Hub, dm orm.DataModel, op string) error {
+	scope := tenantOf(ctx)
+	if scope == nil {
+		return nil
+	}
+	if e := scope.stamp(dm); e != nil {
+		return e
+	}
+	if op == writeInsert {
+		return nil
+	}
+	count, e := aggregateCount(tx, dm, dbflex.And(idFilter(dm), dbflex.Ne(scope.field, scope.value)))
+	if e != nil {
+		return e
+	}
+	if count > 0 {
+		return errNotFound("data not found")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package dbmod
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testTenantID string
+
+func TestTenantStamp(t *testing.T) {
+	f := modelFields(reflect.TypeOf(testModel{}))["Name"]
+	cases := []struct {
+		name  string
+		value interface{}
+		want  string
+		err   bool
+	}{
+		{name: "same type", value: "t1", want: "t1"},
+		{name: "named type of the same kind", value: testTenantID("t2"), want: "t2"},
+		{name: "number to string", value: 65, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dm := new(testModel)
+			err := (&tenantScope{field: "Name", index: f.Index, value: c.value}).stamp(dm)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expecting error, got %q", dm.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dm.Name != c.want {
+				t.Fatalf("got %q, want %q", dm.Name, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package dbmod
+
+import (
+	"fmt"
+
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+)
+
+// TxHubTag is context key of transaction hub used by running write route
+const TxHubTag = "mdb_tx_hub"
+
+// TxHub returns transaction hub of running write route, hooks can use it to write within the same transaction.
+// It returns nil when there is no running transaction
+func TxHub(ctx *kaos.Context) *datahub.Hub {
+	tx, _ := ctx.Data().Get(TxHubTag, nil).(*datahub.Hub)
+	return tx
+}
+
+/*
+withTx runs fn as a unit of work. Any error returned by fn, or a panic, rolls the transaction back,
+otherwise it is committed and commit error is returned.
+fn joins running transaction of the context if there is one, and runs directly on h when it can not start a transaction
+*/
+func withTx(ctx *kaos.Context, h *datahub.Hub, fn func(tx *datahub.Hub) error) (e error) {
+	if tx := TxHub(ctx); tx != nil {
+		return fn(tx)
+	}
+
+	tx, err := h.BeginTx()
+	if err != nil || tx == nil || !tx.IsTx() {
+		return fn(h)
+	}
+	ctx.Data().Set(TxHubTag, tx)
+	defer ctx.Data().Set(TxHubTag, nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if e = fn(tx); e != nil {
+		tx.Rollback()
+		return e
+	}
+	if e = tx.Commit(); e != nil {
+		return fmt.Errorf("commit transaction: %s", e.Error())
+	}
+	return nil
+}
@@ -1,10 +1,68 @@
 package dbmod
 
 import (
+	"git.kanosolution.net/kano/dbflex"
 	"github.com/sebarcode/codekit"
 )
 
+// UpdateFieldRequest is payload of fieldupdate route. Keys is optional, id fields of Model are used when it is nil
 type UpdateFieldRequest struct {
 	Model  codekit.M
 	Fields []string
+	Keys   interface{}
+}
+
+// UpsertRequest is payload of upsert route. MatchFields are fields to find existing data, UniqueFields of the model is used when it is empty.
+// Fields limits fields to be updated when the data already exists
+type UpsertRequest struct {
+	Model       codekit.M
+	MatchFields []string
+	Fields      []string
+}
+
+// PatchRequest is payload of patch route. Keys are positional values or a map of id fields of the model, Patch is a JSON merge patch (RFC 7396)
+type PatchRequest struct {
+	Keys  interface{}
+	Patch codekit.M
+}
+
+// AggregateRequest is payload of aggregate route, each row of the result has GroupBy fields and alias of Aggregates
+type AggregateRequest struct {
+	Where      *dbflex.Filter
+	GroupBy    []string
+	Aggregates []*AggregateItem
+}
+
+// AggregateItem is an aggregate of a field, Op is one of sum, avg, min, max or count.
+// Field of count can be empty to count the rows. Alias defaults to Field, or Count when counting the rows
+type AggregateItem struct {
+	Field string
+	Op    string
+	Alias string
+}
+
+// DistinctRequest is payload of distinct route, WithCount adds number of data of each value to the result
+type DistinctRequest struct {
+	Field     string
+	Where     *dbflex.Filter
+	WithCount bool
+}
+
+// ImportRequest is payload of import route. Data is CSV with header, or NDJSON when Format is ndjson,
+// it is read from ImportReaderTag of context when empty. Op is save (default) or insert,
+// Headers maps CSV header or NDJSON key to field and BatchSize is number of rows written in a transaction
+type ImportRequest struct {
+	Format    string
+	Data      string
+	Op        string
+	Headers   map[string]string
+	BatchSize int
+}
+
+// DeleteQueryResult is result of deletequery route. Count is number of deleted data reported by the driver,
+// or number of matching data counted before deleting when the driver does not report it. IDs is only set on dry run
+type DeleteQueryResult struct {
+	Count  int
+	IDs    []interface{} `json:",omitempty"`
+	DryRun bool
 }
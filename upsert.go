@@ -0,0 +1,92 @@
+package dbmod
+
+import (
+	"reflect"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/ariefdarmawan/serde"
+)
+
+// UniqueModel can be implemented by orm.DataModel to declare natural key used by upsert route when request has no MatchFields
+type UniqueModel interface {
+	UniqueFields() []string
+}
+
+// upsertData updates data matching MatchFields of the request or inserts it when there is none,
+// every match field should be supplied with non-empty value.
+// Existing data is merged with the request, and only Fields of the request are updated when it is not empty
+func (m *mod) upsertData(ctx *kaos.Context, tx *datahub.Hub, model *kaos.ServiceModel, payload *UpsertRequest) (orm.DataModel, error) {
+	if payload == nil || len(payload.Model) == 0 {
+		return nil, errBadRequest("data is nil")
+	}
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return nil, e
+	}
+
+	matchFields := payload.MatchFields
+	if um, ok := model.Model.(UniqueModel); ok && len(matchFields) == 0 {
+		matchFields = um.UniqueFields()
+	}
+	if len(matchFields) == 0 {
+		return nil, errBadRequest("match fields are required")
+	}
+
+	dm := getDataModel(model)
+	if e = serde.Serde(payload.Model, dm); e != nil {
+		return nil, errBadRequest("invalid data: %s", e.Error())
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(dm))
+	filters := []*dbflex.Filter{}
+	for _, matchField := range matchFields {
+		name, _ := lookupField(meta.fields, matchField)
+		f, ok := meta.fields[name]
+		if !ok {
+			return nil, errBadRequest("unknown match field %s", matchField)
+		}
+		if _, ok := keyValue(payload.Model, matchField); !ok {
+			if _, ok = keyValue(payload.Model, name); !ok {
+				return nil, errBadRequest("match field %s is required", matchField)
+			}
+		}
+		fv, err := rv.FieldByIndexErr(f.Index)
+		if err != nil {
+			return nil, errBadRequest("unknown match field %s", matchField)
+		}
+		if fv.IsZero() {
+			return nil, errBadRequest("match field %s should not be empty", matchField)
+		}
+		filters = append(filters, dbflex.Eq(name, fv.Interface()))
+	}
+	where := joinFilters(dbflex.And, append(filters, filtersFromCtx(ctx, meta.softDelete)...))
+
+	count, e := aggregateCount(tx, dm, where)
+	if e != nil {
+		return nil, e
+	}
+	switch count {
+	case 0:
+		return dm, m.writeData(ctx, tx, model, dm, writeInsert)
+	case 1:
+	default:
+		return nil, errConflict("%d data match %v", count, matchFields)
+	}
+
+	existing := getDataModel(model)
+	if e = tx.GetByFilter(existing, where); e != nil {
+		return nil, e
+	}
+	keys := dataKeys(existing)
+	if e = serde.Serde(payload.Model, existing); e != nil {
+		return nil, errBadRequest("invalid data: %s", e.Error())
+	}
+	existing.SetID(keys...)
+	if meta.version != nil {
+		meta.version.ifMatch(ctx, existing)
+	}
+	return existing, m.writeData(ctx, tx, model, existing, writeUpdate, payload.Fields...)
+}
@@ -0,0 +1,262 @@
+package dbmod
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/serde"
+	"github.com/sebarcode/codekit"
+)
+
+/*
+ValidateRuleTag declares validation rules of a model field, evaluated on every generated write route.
+Value is comma separated of: required, min=n, max=n, regex=pattern and enum=a|b|c.
+min and max check length of string, slice and map, and value of number
+*/
+const ValidateRuleTag = "mdb_rule"
+
+// FieldError is a violation of validation rule on a field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Validator validates data, given as codekit.M, and returns its violations.
+// It can be set as ValidateFnTag, func(codekit.M) bool is still accepted
+type Validator func(data codekit.M) []*FieldError
+
+func validationError(errs []*FieldError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &Error{Code: http.StatusUnprocessableEntity, Message: "validate data error", Details: errs}
+}
+
+// ValidationErrors returns field errors of an error returned by generated routes, nil if it is not a validation error
+func ValidationErrors(e error) []*FieldError {
+	var de *Error
+	if !errors.As(e, &de) {
+		return nil
+	}
+	errs, _ := de.Details.([]*FieldError)
+	return errs
+}
+
+// validateFromCtx runs validator of context when ValidateTag is on, data is a model or codekit.M
+func validateFromCtx(ctx *kaos.Context, data interface{}) error {
+	if on, _ := ctx.Data().Get(ValidateTag, false).(bool); !on {
+		return nil
+	}
+	dataM, ok := data.(codekit.M)
+	if !ok {
+		dataM, _ = codekit.ToM(data)
+	}
+	return validationError(validateFn(ctx.Data().Get(ValidateFnTag, nil), dataM))
+}
+
+// ValidationOptOut can be implemented by orm.DataModel to skip validation on some generated write routes.
+// savemany, insertmany and updatemany follow save, insert and update respectively, upsert follows insert or update
+type ValidationOptOut interface {
+	NoValidateRoutes() []string
+}
+
+// validateData is validation pipeline of write routes, validator of context then rules of the model.
+// When fields is not empty, only those fields are validated
+func (m *mod) validateData(ctx *kaos.Context, model *kaos.ServiceModel, route string, dm orm.DataModel, fields ...string) error {
+	if om, ok := model.Model.(ValidationOptOut); ok && codekit.HasMember(om.NoValidateRoutes(), route) {
+		return nil
+	}
+
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return e
+	}
+
+	errs := []*FieldError{}
+	if on, _ := ctx.Data().Get(ValidateTag, false).(bool); on {
+		dataM, _ := codekit.ToM(dm)
+		if len(fields) > 0 {
+			for _, key := range dataM.Keys() {
+				if !hasFieldName(fields, key) {
+					delete(dataM, key)
+				}
+			}
+		}
+		errs = append(errs, validateFn(ctx.Data().Get(ValidateFnTag, nil), dataM)...)
+		serde.Serde(dataM, dm)
+	}
+	errs = append(errs, validateRules(meta.rules, dm, fields...)...)
+	return validationError(errs)
+}
+
+type fieldRule struct {
+	field string
+	index []int
+	code  string
+	arg   string
+	check func(fv reflect.Value) bool
+}
+
+var ruleNames = []string{"required", "min", "max", "regex", "enum"}
+
+func parseRules(fields map[string]reflect.StructField) ([]*fieldRule, error) {
+	res := []*fieldRule{}
+	for name, f := range fields {
+		tag, ok := f.Tag.Lookup(ValidateRuleTag)
+		if !ok {
+			continue
+		}
+
+		// part which is not a rule is continuation of previous one, ie comma in regex
+		parts := []string{}
+		for _, part := range strings.Split(tag, ",") {
+			ruleName := strings.SplitN(part, "=", 2)[0]
+			if len(parts) > 0 && !codekit.HasMember(ruleNames, strings.TrimSpace(ruleName)) {
+				parts[len(parts)-1] += "," + part
+				continue
+			}
+			parts = append(parts, part)
+		}
+
+		for _, part := range parts {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			rule := &fieldRule{field: name, index: f.Index, code: kv[0]}
+			if len(kv) > 1 {
+				rule.arg = kv[1]
+			}
+			if e := rule.compile(); e != nil {
+				return nil, fmt.Errorf("rule of %s: %s", name, e.Error())
+			}
+			res = append(res, rule)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].field < res[j].field
+	})
+	return res, nil
+}
+
+func (r *fieldRule) compile() error {
+	switch r.code {
+	case "required":
+		r.check = func(fv reflect.Value) bool {
+			if fv.Kind() == reflect.String {
+				return strings.TrimSpace(fv.String()) != ""
+			}
+			return !fv.IsZero()
+		}
+
+	case "min", "max":
+		limit, err := strconv.ParseFloat(r.arg, 64)
+		if err != nil {
+			return fmt.Errorf("%s needs a number", r.code)
+		}
+		isMin := r.code == "min"
+		r.check = func(fv reflect.Value) bool {
+			fv = reflect.Indirect(fv)
+			var n float64
+			switch fv.Kind() {
+			case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+				n = float64(fv.Len())
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				n = float64(fv.Int())
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				n = float64(fv.Uint())
+			case reflect.Float32, reflect.Float64:
+				n = fv.Float()
+			default:
+				return true
+			}
+			if isMin {
+				return n >= limit
+			}
+			return n <= limit
+		}
+
+	case "regex":
+		rx, err := regexp.Compile(r.arg)
+		if err != nil {
+			return err
+		}
+		r.check = func(fv reflect.Value) bool {
+			fv = reflect.Indirect(fv)
+			if fv.Kind() != reflect.String || fv.String() == "" {
+				return true
+			}
+			return rx.MatchString(fv.String())
+		}
+
+	case "enum":
+		options := strings.Split(r.arg, "|")
+		r.check = func(fv reflect.Value) bool {
+			fv = reflect.Indirect(fv)
+			if !fv.IsValid() || fv.IsZero() {
+				return true
+			}
+			return codekit.HasMember(options, fmt.Sprintf("%v", fv.Interface()))
+		}
+
+	default:
+		return fmt.Errorf("unknown rule %s", r.code)
+	}
+	return nil
+}
+
+func (r *fieldRule) message() string {
+	switch r.code {
+	case "required":
+		return fmt.Sprintf("%s is required", r.field)
+	case "min":
+		return fmt.Sprintf("%s should be at least %s", r.field, r.arg)
+	case "max":
+		return fmt.Sprintf("%s should be at most %s", r.field, r.arg)
+	case "enum":
+		return fmt.Sprintf("%s should be one of %s", r.field, strings.ReplaceAll(r.arg, "|", ", "))
+	}
+	return fmt.Sprintf("%s is not valid", r.field)
+}
+
+// validateRules evaluates rules against data, only rules of given fields are evaluated when fields is not empty
+func validateRules(rules []*fieldRule, data interface{}, fields ...string) []*FieldError {
+	rv := reflect.Indirect(reflect.ValueOf(data))
+	errs := []*FieldError{}
+	for _, rule := range rules {
+		if len(fields) > 0 && !hasFieldName(fields, rule.field) {
+			continue
+		}
+		fv, err := rv.FieldByIndexErr(rule.index)
+		if err != nil {
+			continue
+		}
+		if !rule.check(fv) {
+			errs = append(errs, &FieldError{Field: rule.field, Code: rule.code, Message: rule.message()})
+		}
+	}
+	return errs
+}
+
+// validateFn runs validator set as ValidateFnTag
+func validateFn(fn interface{}, data codekit.M) []*FieldError {
+	switch fn := fn.(type) {
+	case Validator:
+		return fn(data)
+
+	case func(codekit.M) []*FieldError:
+		return fn(data)
+
+	case func(codekit.M) bool:
+		if fn(data) {
+			return nil
+		}
+	}
+	return []*FieldError{{Code: "invalid", Message: "validate data error"}}
+}
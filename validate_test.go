@@ -0,0 +1,83 @@
+package dbmod
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	type ruleModel struct {
+		Code   string `mdb_rule:"required,regex=^[a-z]{1,3}$"`
+		Qty    int    `mdb_rule:"min=1, max=10"`
+		Status string `mdb_rule:"enum=open|closed"`
+		Note   string
+	}
+
+	rules, err := parseRules(modelFields(reflect.TypeOf(ruleModel{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := [][]string{}
+	for _, r := range rules {
+		got = append(got, []string{r.field, r.code, r.arg})
+	}
+	want := [][]string{
+		{"Code", "required", ""},
+		{"Code", "regex", "^[a-z]{1,3}$"},
+		{"Qty", "min", "1"},
+		{"Qty", "max", "10"},
+		{"Status", "enum", "open|closed"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	cases := []struct {
+		name string
+		data ruleModel
+		want []string
+	}{
+		{name: "valid", data: ruleModel{Code: "ab", Qty: 1, Status: "open"}, want: []string{}},
+		{name: "empty enum is valid", data: ruleModel{Code: "ab", Qty: 10}, want: []string{}},
+		{name: "required", data: ruleModel{Code: " ", Qty: 1}, want: []string{"Code:required", "Code:regex"}},
+		{name: "regex", data: ruleModel{Code: "abcd", Qty: 1}, want: []string{"Code:regex"}},
+		{name: "min", data: ruleModel{Code: "a"}, want: []string{"Qty:min"}},
+		{name: "max", data: ruleModel{Code: "a", Qty: 11}, want: []string{"Qty:max"}},
+		{name: "enum", data: ruleModel{Code: "a", Qty: 1, Status: "draft"}, want: []string{"Status:enum"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := []string{}
+			for _, fe := range validateRules(rules, &c.data) {
+				got = append(got, fe.Field+":"+fe.Code)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseRulesError(t *testing.T) {
+	cases := []struct {
+		name  string
+		model interface{}
+	}{
+		{name: "unknown rule", model: struct {
+			F string `mdb_rule:"unique"`
+		}{}},
+		{name: "min is not a number", model: struct {
+			F int `mdb_rule:"min=x"`
+		}{}},
+		{name: "invalid regex", model: struct {
+			F string `mdb_rule:"regex=["`
+		}{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if rules, err := parseRules(modelFields(reflect.TypeOf(c.model))); err == nil {
+				t.Fatalf("expecting error, got %d rules", len(rules))
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package dbmod
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/sebarcode/codekit"
+)
+
+// VersionedModel can be implemented by orm.DataModel to activate optimistic concurrency control.
+// The field should be an integer, save, update and fieldupdate only succeed when its value equals the one in database
+// (or http If-Match header on routes writing single data) and it is increased on every write
+type VersionedModel interface {
+	VersionField() string
+}
+
+type versioning struct {
+	field string
+	index []int
+}
+
+func newVersioning(mdl interface{}, fields map[string]reflect.StructField) (*versioning, error) {
+	vm, ok := mdl.(VersionedModel)
+	if !ok {
+		return nil, nil
+	}
+
+	field := vm.VersionField()
+	f, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("version: %s is not a field", field)
+	}
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		return nil, fmt.Errorf("version: %s should be an integer field", field)
+	}
+	return &versioning{field: field, index: f.Index}, nil
+}
+
+func (v *versioning) get(dm orm.DataModel) int64 {
+	fv, err := reflect.Indirect(reflect.ValueOf(dm)).FieldByIndexErr(v.index)
+	if err != nil {
+		return 0
+	}
+	return fv.Int()
+}
+
+func (v *versioning) set(dm orm.DataModel, n int64) {
+	fv, err := reflect.Indirect(reflect.ValueOf(dm)).FieldByIndexErr(v.index)
+	if err != nil {
+		return
+	}
+	fv.SetInt(n)
+}
+
+// check makes sure data has not been changed by others and increases its version, it returns filter of id and expected
+// version to update data with, nil when data is new. Save of data that does not exist yet and insert start the version from 1
+func (v *versioning) check(h *datahub.Hub, dm orm.DataModel, op string) (*dbflex.Filter, error) {
+	if op == writeInsert {
+		v.set(dm, 1)
+		return nil, nil
+	}
+
+	expected := v.get(dm)
+	if op == writeSave && expected == 0 {
+		count, e := aggregateCount(h, dm, idFilter(dm))
+		if e != nil {
+			return nil, e
+		}
+		if count == 0 {
+			v.set(dm, 1)
+			return nil, nil
+		}
+	}
+
+	where := dbflex.And(idFilter(dm), dbflex.Eq(v.field, expected))
+	count, e := aggregateCount(h, dm, where)
+	if e != nil {
+		return nil, e
+	}
+	if count == 0 {
+		return nil, errConflict("data has been changed, version %d is no longer current", expected)
+	}
+	v.set(dm, expected+1)
+	return where, nil
+}
+
+// update writes data only where its version is still the expected one, so data changed after check is not overwritten.
+// It is what hub's UpdateField does, but it keeps result of the driver to report conflict when no data matched
+func (v *versioning) update(h *datahub.Hub, dm orm.DataModel, where *dbflex.Filter, fields ...string) error {
+	idx, conn, e := h.GetConnection()
+	if e != nil {
+		return fmt.Errorf("connection error. %s", e.Error())
+	}
+	defer h.CloseConnection(idx, conn)
+
+	dm.SetThis(dm)
+	if e = dm.PreSave(conn); e != nil {
+		return e
+	}
+	res, e := conn.Execute(dbflex.From(dm.TableName()).Where(where).Update(fields...), codekit.M{}.Set("data", dm))
+	if e != nil {
+		return e
+	}
+	if n, ok := affectedRows(res); ok && n == 0 {
+		return errConflict("data has been changed, version %d is no longer current", v.get(dm)-1)
+	}
+	dm.PostSave(conn)
+	return nil
+}
+
+// updateFields writes fields of fieldupdate route when version of current data is the one of obj (or http If-Match header),
+// the version is increased with the fields
+func (v *versioning) updateFields(ctx *kaos.Context, h *datahub.Hub, tableName string, current orm.DataModel, where *dbflex.Filter,
+	obj codekit.M, fields ...string) error {
+	expected, ok := ifMatchVersion(ctx)
+	if !ok {
+		expected = int64(obj.GetInt(v.field))
+	}
+	if v.get(current) != expected {
+		return errConflict("data has been changed, version %d is no longer current", expected)
+	}
+
+	obj.Set(v.field, expected+1)
+	if len(fields) > 0 && !codekit.HasMember(fields, v.field) {
+		fields = append(fields, v.field)
+	}
+	res, e := h.Execute(dbflex.From(tableName).Where(dbflex.And(where, dbflex.Eq(v.field, expected))).Update(fields...),
+		codekit.M{}.Set("data", obj))
+	if e != nil {
+		return e
+	}
+	if n, ok := affectedRows(res); ok && n == 0 {
+		return errConflict("data has been changed, version %d is no longer current", expected)
+	}
+	return nil
+}
+
+// ifMatch sets version of data from http If-Match header, it is used by routes writing single data only
+func (v *versioning) ifMatch(ctx *kaos.Context, dm orm.DataModel) {
+	if dm == nil || dmIsNil(dm) {
+		return
+	}
+	if n, ok := ifMatchVersion(ctx); ok {
+		v.set(dm, n)
+	}
+}
+
+// setETag exposes version of data as http ETag header
+func (v *versioning) setETag(ctx *kaos.Context, dm orm.DataModel) {
+	if w, ok := ctx.Data().Get("http_writer", nil).(http.ResponseWriter); ok {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(v.get(dm), 10)))
+	}
+}
+
+func ifMatchVersion(ctx *kaos.Context) (int64, bool) {
+	hr, ok := ctx.Data().Get("http_request", nil).(*http.Request)
+	if !ok {
+		return 0, false
+	}
+	tag := strings.TrimPrefix(strings.TrimSpace(hr.Header.Get("If-Match")), "W/")
+	if tag == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.Trim(tag, "\""), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
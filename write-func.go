@@ -0,0 +1,198 @@
+package dbmod
+
+import (
+	"fmt"
+	"reflect"
+
+	"git.kanosolution.net/kano/dbflex"
+	"git.kanosolution.net/kano/dbflex/orm"
+	"git.kanosolution.net/kano/kaos"
+	"github.com/ariefdarmawan/datahub"
+	"github.com/ariefdarmawan/serde"
+	"github.com/sebarcode/codekit"
+)
+
+const (
+	writeSave   = "save"
+	writeInsert = "insert"
+	writeUpdate = "update"
+	writePatch  = "patch"
+)
+
+// BulkModeTag is context key to set mode of savemany, insertmany and updatemany routes, value is BulkAtomic (default) or BulkPartial
+const BulkModeTag = "mdb_bulk_mode"
+
+const (
+	// BulkAtomic writes all data in a transaction and rolls back everything when one of them fails
+	BulkAtomic = "atomic"
+	// BulkPartial writes each data in its own transaction and reports result per data
+	BulkPartial = "partial"
+)
+
+// BulkResult is result of each data on savemany, insertmany and updatemany routes
+type BulkResult struct {
+	Index int
+	Data  orm.DataModel
+	Error string
+}
+
+// callHook calls hook of the model, or the fallback one when the model does not have it
+func callHook(ctx *kaos.Context, model *kaos.ServiceModel, name, fallback string, payload interface{}) error {
+	if fallback != "" && !model.HasHook(name) {
+		name = fallback
+	}
+	return model.CallHook(name, ctx, payload)
+}
+
+// writeHooks returns names of pre and post hook of a write operation.
+// Insert, update and patch call PreInsert/PostInsert, PreUpdate/PostUpdate and PrePatch/PostPatch, all fall back to PreSave/PostSave
+func writeHooks(op string) (string, string) {
+	switch op {
+	case writeInsert:
+		return "PreInsert", "PostInsert"
+	case writeUpdate:
+		return "PreUpdate", "PostUpdate"
+	case writePatch:
+		return "PrePatch", "PostPatch"
+	}
+	return "PreSave", "PostSave"
+}
+
+// prepareData runs pre hook and validation of a write operation, it is the part of writeData which does not touch database
+func (m *mod) prepareData(ctx *kaos.Context, model *kaos.ServiceModel, dm orm.DataModel, op string) error {
+	if dmIsNil(dm) {
+		return fmt.Errorf("data is nil")
+	}
+	preHook, _ := writeHooks(op)
+	if e := callHook(ctx, model, preHook, "PreSave", dm); e != nil {
+		return e
+	}
+	return m.validateData(ctx, model, op, dm)
+}
+
+// writeData runs hooks around save, insert, update or patch of single data using given hub, see writeHooks.
+// fields limits fields to be saved, Fields of context is used when it is empty
+func (m *mod) writeData(ctx *kaos.Context, tx *datahub.Hub, model *kaos.ServiceModel, dm orm.DataModel, op string, fields ...string) error {
+	if e := m.prepareData(ctx, model, dm, op); e != nil {
+		return e
+	}
+	return m.storeData(ctx, tx, model, dm, op, fields...)
+}
+
+// storeData is the part of writeData after prepareData, it writes data to database then runs post hook and audit
+func (m *mod) storeData(ctx *kaos.Context, tx *datahub.Hub, model *kaos.ServiceModel, dm orm.DataModel, op string, fields ...string) error {
+	meta, e := m.getMeta(model)
+	if e != nil {
+		return e
+	}
+	if e = guardTenant(ctx, tx, dm, op); e != nil {
+		return e
+	}
+	if meta.softDelete != nil {
+		if e = meta.softDelete.guard(tx, dm, op); e != nil {
+			return e
+		}
+	}
+
+	if len(fields) == 0 {
+		fields = ctx.Data().Get("Fields", []string{}).([]string)
+	}
+	var versionWhere *dbflex.Filter
+	if meta.version != nil {
+		if versionWhere, e = meta.version.check(tx, dm, op); e != nil {
+			return e
+		}
+		if len(fields) > 0 && !codekit.HasMember(fields, meta.version.field) {
+			fields = append(append([]string{}, fields...), meta.version.field)
+		}
+	}
+
+	var before orm.DataModel
+	if op != writeInsert && isAudited(model) {
+		before = loadCurrent(tx, model, idFilter(dm))
+	}
+
+	switch {
+	case op == writeInsert:
+		e = tx.Insert(dm)
+	case versionWhere != nil:
+		e = meta.version.update(tx, dm, versionWhere, fields...)
+	case op == writeUpdate || op == writePatch:
+		e = tx.Update(dm, fields...)
+	default:
+		if e = tx.Save(dm, fields...); e != nil {
+			e = ctx.Log().Error2("error when save data", "save error: %s", e.Error())
+		}
+	}
+	if e != nil {
+		return e
+	}
+
+	_, postHook := writeHooks(op)
+	if e = callHook(ctx, model, postHook, "PostSave", dm); e != nil {
+		return e
+	}
+	return m.audit(ctx, tx, model, op, dataKeys(dm), before, dm)
+}
+
+func (m *mod) writeMany(ctx *kaos.Context, model *kaos.ServiceModel, dms []orm.DataModel, op string) ([]*BulkResult, error) {
+	h := m.getHub(ctx)
+	results := make([]*BulkResult, len(dms))
+
+	if ctx.Data().Get(BulkModeTag, BulkAtomic) == BulkPartial {
+		for idx, dm := range dms {
+			results[idx] = &BulkResult{Index: idx, Data: dm}
+			e := withTx(ctx, h, func(tx *datahub.Hub) error {
+				return m.writeData(ctx, tx, model, dm, op)
+			})
+			if e != nil {
+				results[idx].Error = e.Error()
+			}
+		}
+		return results, nil
+	}
+
+	e := withTx(ctx, h, func(tx *datahub.Hub) error {
+		for idx, dm := range dms {
+			if e := m.writeData(ctx, tx, model, dm, op); e != nil {
+				return fmt.Errorf("data %d: %w", idx, e)
+			}
+			results[idx] = &BulkResult{Index: idx, Data: dm}
+		}
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return results, nil
+}
+
+// toDataModels converts payload of bulk routes, a slice of model or of map, into data models
+func toDataModels(model *kaos.ServiceModel, payload interface{}) ([]orm.DataModel, error) {
+	rv := reflect.Indirect(reflect.ValueOf(payload))
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("payload should be a slice of %s", model.Name)
+	}
+
+	dms := make([]orm.DataModel, rv.Len())
+	for idx := range dms {
+		item := rv.Index(idx)
+		for item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		if item.Kind() == reflect.Struct && item.CanAddr() {
+			item = item.Addr()
+		}
+		if dm, ok := item.Interface().(orm.DataModel); ok {
+			dms[idx] = dm
+			continue
+		}
+
+		dm := getDataModel(model)
+		if e := serde.Serde(item.Interface(), dm); e != nil {
+			return nil, fmt.Errorf("data %d: %s", idx, e.Error())
+		}
+		dms[idx] = dm
+	}
+	return dms, nil
+}